@@ -0,0 +1,106 @@
+package go_spec
+
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/armory-io/go-spec/logging"
+)
+
+// AccessLoggerName is the name of the logger access logs are written to,
+// its level can be managed like any other named logger
+const AccessLoggerName = "http.access"
+
+// AccessLogConfig configures the access log written for every request
+// served by the ApplicationContext. It is read from the `logging.access`
+// block of the application's configuration
+type AccessLogConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// ExcludePaths lists request path prefixes that are never logged
+	ExcludePaths []string `yaml:"excludePaths"`
+
+	// SuccessSampleRate is the fraction, between 0 and 1, of successful
+	// requests that are logged. Failed requests are always logged
+	SuccessSampleRate float64 `yaml:"successSampleRate"`
+}
+
+// DefaultAccessLogConfig logs every request except health checks
+func DefaultAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{
+		Enabled:           true,
+		ExcludePaths:      []string{"/health"},
+		SuccessSampleRate: 1,
+	}
+}
+
+// AccessLogSettings is used to extract the access log
+// configuration from the application's configuration
+type AccessLogSettings struct {
+	Logging struct {
+		Access AccessLogConfig `yaml:"access"`
+	} `yaml:"logging"`
+}
+
+type accessLogger struct {
+	cfg    AccessLogConfig
+	logger logging.LeveledLogger
+	sample func() float64
+}
+
+// AccessLogMiddleware logs a line for every request handled by next, including
+// the method, URI template, status, outcome, latency, bytes written, remote
+// address and user agent. The URI template is only known for requests routed
+// by a gorilla/mux router served by the ApplicationContext, the request path is
+// logged otherwise
+func AccessLogMiddleware(cfg AccessLogConfig, logger logging.LeveledLogger) func(http.Handler) http.Handler {
+	al := &accessLogger{cfg: cfg, logger: logger, sample: rand.Float64}
+	return al.middleware
+}
+
+func (al *accessLogger) middleware(next http.Handler) http.Handler {
+	if !al.cfg.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if al.excluded(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r, info := withRequestInfo(r)
+		wrappedWriter := wrapResponseWriter(w)
+		startTime := time.Now()
+		next.ServeHTTP(wrappedWriter, r)
+		latency := time.Since(startTime)
+
+		status := wrappedWriter.status()
+		if status < 400 && al.sample() >= al.cfg.SuccessSampleRate {
+			return
+		}
+		uri := info.uriTemplate
+		if uri == "" {
+			uri = r.URL.Path
+		}
+		al.logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+			"method":     r.Method,
+			"uri":        uri,
+			"status":     status,
+			"outcome":    codeToOutcome(status),
+			"latencyMs":  float64(latency) / float64(time.Millisecond),
+			"bytes":      wrappedWriter.bytesWritten,
+			"remoteAddr": r.RemoteAddr,
+			"userAgent":  r.UserAgent(),
+		}).Infof("%s %s %d", r.Method, uri, status)
+	})
+}
+
+func (al *accessLogger) excluded(r *http.Request) bool {
+	for _, prefix := range al.cfg.ExcludePaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
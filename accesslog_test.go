@@ -0,0 +1,95 @@
+package go_spec
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armory-io/go-spec/logging"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBufferedLogger(t *testing.T) (logging.LeveledLogger, *bytes.Buffer) {
+	logger, err := logging.NewLeveledLogger(logging.Config{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	adapter := logger.(*logging.LogrusAdapter)
+	adapter.Logger.SetOutput(&buf)
+	adapter.Logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+	return logger, &buf
+}
+
+func TestAccessLogMiddleware(t *testing.T) {
+	cases := map[string]struct {
+		cfg         AccessLogConfig
+		path        string
+		expectedLog []string
+	}{
+		"logs templated uri": {
+			cfg:         DefaultAccessLogConfig(),
+			path:        "/applications/spinnaker",
+			expectedLog: []string{`uri="/applications/{name}"`, "status=200", "outcome=SUCCESS", "bytes=5", "method=GET", `userAgent=test-agent`},
+		},
+		"logs path when no route matches": {
+			cfg:         DefaultAccessLogConfig(),
+			path:        "/unknown",
+			expectedLog: []string{"uri=/unknown", "status=404", "outcome=CLIENT_ERROR"},
+		},
+		"logs a 200 when the handler writes nothing": {
+			cfg:         DefaultAccessLogConfig(),
+			path:        "/empty",
+			expectedLog: []string{`uri=/empty`, "status=200", "outcome=SUCCESS", `msg="GET /empty 200"`},
+		},
+		"skips excluded paths": {
+			cfg:         DefaultAccessLogConfig(),
+			path:        "/health",
+			expectedLog: nil,
+		},
+		"samples successful requests": {
+			cfg:         AccessLogConfig{Enabled: true, SuccessSampleRate: 0},
+			path:        "/applications/spinnaker",
+			expectedLog: nil,
+		},
+		"always logs failed requests": {
+			cfg:         AccessLogConfig{Enabled: true, SuccessSampleRate: 0},
+			path:        "/fail",
+			expectedLog: []string{"status=500", "outcome=SERVER_ERROR"},
+		},
+		"disabled": {
+			cfg:         AccessLogConfig{},
+			path:        "/fail",
+			expectedLog: nil,
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			logger, buf := newBufferedLogger(t)
+			router := mux.NewRouter()
+			router.Use(routeTemplateMiddleware)
+			router.HandleFunc("/applications/{name}", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			})
+			router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {})
+			router.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {})
+			router.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, c.path, nil)
+			req.Header.Set("User-Agent", "test-agent")
+			AccessLogMiddleware(c.cfg, logger)(router).ServeHTTP(httptest.NewRecorder(), req)
+
+			if c.expectedLog == nil {
+				assert.Empty(t, buf.String())
+			}
+			for _, expected := range c.expectedLog {
+				assert.Contains(t, buf.String(), expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package go_spec
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/armon/go-metrics"
+)
+
+// DefaultMaxLabelValues is the number of distinct values a label
+// can take for a single metric before new values are collapsed
+const DefaultMaxLabelValues = 100
+
+// OverflowLabelValue replaces the values of a label
+// once it has reached its maximum number of values
+const OverflowLabelValue = "OTHER"
+
+// cardinalityLimiter caps the number of distinct values each label can take
+// per metric so that labels fed from requests, like uri, can't create an
+// unbounded number of series. Values beyond the cap are reported as
+// OverflowLabelValue and counted in metrics.cardinality.overflow
+type cardinalityLimiter struct {
+	next        metrics.MetricSink
+	serviceName string
+	maxValues   int
+
+	mu sync.Mutex
+	// seen holds the accepted values of each label, keyed by metric then label
+	seen map[string]map[string]map[string]bool
+}
+
+func newCardinalityLimiter(next metrics.MetricSink, serviceName string, maxValues int) *cardinalityLimiter {
+	return &cardinalityLimiter{
+		next:        next,
+		serviceName: serviceName,
+		maxValues:   maxValues,
+		seen:        map[string]map[string]map[string]bool{},
+	}
+}
+
+func (cl *cardinalityLimiter) SetGauge(key []string, val float32) {
+	cl.next.SetGauge(key, val)
+}
+
+func (cl *cardinalityLimiter) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	cl.next.SetGaugeWithLabels(key, val, cl.limit(key, labels))
+}
+
+func (cl *cardinalityLimiter) EmitKey(key []string, val float32) {
+	cl.next.EmitKey(key, val)
+}
+
+func (cl *cardinalityLimiter) IncrCounter(key []string, val float32) {
+	cl.next.IncrCounter(key, val)
+}
+
+func (cl *cardinalityLimiter) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	cl.next.IncrCounterWithLabels(key, val, cl.limit(key, labels))
+}
+
+func (cl *cardinalityLimiter) AddSample(key []string, val float32) {
+	cl.next.AddSample(key, val)
+}
+
+func (cl *cardinalityLimiter) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	cl.next.AddSampleWithLabels(key, val, cl.limit(key, labels))
+}
+
+// limit returns labels with every value over its label's cap replaced
+func (cl *cardinalityLimiter) limit(key []string, labels []metrics.Label) []metrics.Label {
+	if len(labels) == 0 {
+		return labels
+	}
+	name := strings.Join(key, ".")
+	var limited []metrics.Label
+	var overflowed []string
+
+	cl.mu.Lock()
+	byLabel, ok := cl.seen[name]
+	if !ok {
+		byLabel = map[string]map[string]bool{}
+		cl.seen[name] = byLabel
+	}
+	for i, l := range labels {
+		values, ok := byLabel[l.Name]
+		if !ok {
+			values = map[string]bool{}
+			byLabel[l.Name] = values
+		}
+		if values[l.Value] {
+			continue
+		}
+		if len(values) < cl.maxValues {
+			values[l.Value] = true
+			continue
+		}
+		if limited == nil {
+			// labels belong to the caller, copy before replacing values
+			limited = append([]metrics.Label{}, labels...)
+		}
+		limited[i].Value = OverflowLabelValue
+		overflowed = append(overflowed, l.Name)
+	}
+	cl.mu.Unlock()
+
+	for _, label := range overflowed {
+		cl.next.IncrCounterWithLabels(cl.overflowKey(), 1, []metrics.Label{
+			{Name: "metric", Value: stripServiceName(cl.serviceName, key)},
+			{Name: "label", Value: label},
+		})
+	}
+	if limited == nil {
+		return labels
+	}
+	return limited
+}
+
+// stripServiceName returns the metric name of key without the
+// service name armon prepends to every key
+func stripServiceName(serviceName string, key []string) string {
+	if len(key) > 1 && key[0] == serviceName {
+		key = key[1:]
+	}
+	return strings.Join(key, ".")
+}
+
+func (cl *cardinalityLimiter) overflowKey() []string {
+	if cl.serviceName == "" {
+		return []string{"metrics.cardinality.overflow"}
+	}
+	return []string{cl.serviceName, "metrics.cardinality.overflow"}
+}
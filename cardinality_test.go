@@ -0,0 +1,57 @@
+package go_spec
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCardinalityLimiter(t *testing.T) {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	cl := newCardinalityLimiter(sink, "app", 2)
+
+	for i := 0; i < 5; i++ {
+		labels := []metrics.Label{
+			{Name: "uri", Value: fmt.Sprintf("/applications/%d", i)},
+			{Name: "method", Value: "GET"},
+		}
+		cl.IncrCounterWithLabels([]string{"app", "http.server.requests"}, 1, labels)
+		// the caller's labels are left untouched
+		assert.Equal(t, fmt.Sprintf("/applications/%d", i), labels[0].Value)
+	}
+	// other metrics have their own caps
+	cl.IncrCounterWithLabels([]string{"app", "http.client.requests"}, 1, []metrics.Label{{Name: "uri", Value: "/applications/4"}})
+
+	counts := map[string]int{}
+	for _, interval := range sink.Data() {
+		for _, c := range interval.Counters {
+			counts[c.Name+" "+labelValue(c.Labels, "uri")+labelValue(c.Labels, "label")] += c.Count
+		}
+	}
+	assert.Equal(t, map[string]int{
+		"app.http.server.requests /applications/0": 1,
+		"app.http.server.requests /applications/1": 1,
+		"app.http.server.requests OTHER":           3,
+		"app.http.client.requests /applications/4": 1,
+		"app.metrics.cardinality.overflow uri":     3,
+	}, counts)
+}
+
+func labelValue(labels []metrics.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func TestDefaultURIMapperFunc(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/applications/deck%2Fgate?expand=true", nil)
+	assert.Equal(t, "/applications/deck%2Fgate", DefaultURIMapperFunc(r))
+}
@@ -3,20 +3,31 @@ package go_spec
 import (
 	"context"
 	"net/http"
+	"time"
 
+	"github.com/armory-io/go-spec/logging"
 	"github.com/armory/go-yaml-tools/pkg/spring"
 	"github.com/armory/go-yaml-tools/pkg/tls/server"
-	slog "github.com/go-eden/slf4go"
 	"github.com/gorilla/mux"
 	"github.com/mitchellh/mapstructure"
+	"go.uber.org/multierr"
 )
 
 type applicationContext struct {
-	logger *slog.Logger
-	router *mux.Router
-	config map[string]interface{}
-	server *server.Server
-	ms     *MetricsServer
+	logger     logging.LeveledLogger
+	accessLog  AccessLogConfig
+	httpClient HTTPClientConfig
+	services   *ServiceRegistry
+	tracing    *Tracing
+	router     *mux.Router
+	config     map[string]interface{}
+	server     *webServer
+	ms         *MetricsServer
+	health     *HealthRegistry
+
+	ctx         context.Context
+	lifecycle   *lifecycle
+	stopMetrics context.CancelFunc
 }
 
 // ApplicationContextConfig is used to supply the ApplicationContext
@@ -31,8 +42,13 @@ type ApplicationContextConfig struct {
 	ConfigNames []string
 
 	// Ctx allows users to supply a primary context that will
-	// be used by all components provided by the ApplicationContext
+	// be used by all components provided by the ApplicationContext.
+	// Canceling it shuts the application down
 	Ctx context.Context
+
+	// ShutdownTimeout bounds the total time spent draining the web server,
+	// stop hooks and metrics server. Defaults to DefaultShutdownTimeout
+	ShutdownTimeout time.Duration
 }
 
 // ServerConfig is used to extract configuration information
@@ -41,6 +57,12 @@ type ServerConfig struct {
 	Server server.ServerConfig `yaml:"server"`
 }
 
+// LoggingConfig is used to extract configuration information
+// about how the application's logger should be configured
+type LoggingConfig struct {
+	Logging logging.Config `yaml:"logging"`
+}
+
 // NewApplicationContext provides all of the common utilities needed to make
 // building an observable application simple. Using the ApplicationContext's
 // logger, router & server will ensure that the application is instrumented
@@ -53,35 +75,123 @@ func NewApplicationContext(acc ApplicationContextConfig) (*applicationContext, e
 		return nil, err
 	}
 
-	// TODO: setup logging based on the application configuration
-	logger := slog.NewLogger(acc.Name)
-
 	ctx := acc.Ctx
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	health := NewHealthRegistry()
+	ac := &applicationContext{
+		router:    mux.NewRouter(),
+		config:    cfg,
+		health:    health,
+		ctx:       ctx,
+		lifecycle: newLifecycle(acc.ShutdownTimeout),
+	}
+
+	var mss MetricsSettings
+	if err := ac.GetConfig(&mss); err != nil {
+		return nil, err
+	}
+	var uriMapper URIMapperFunc
+	if len(mss.Metrics.URIPatterns) > 0 {
+		uriMapper, err = PatternURIMapperFunc(mss.Metrics.URIPatterns, DefaultURIMapperFunc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the metrics server is stopped by the lifecycle after everything
+	// else has drained, so it must not observe the primary context directly
+	msCtx, stopMetrics := context.WithCancel(context.Background())
 	msc := MetricsServerConfig{
-		ServiceName: acc.Name,
-		Addr:        DefaultObservabilityAddr,
-		Path:        DefaultObservabilityPath,
-		Ctx:         ctx,
+		ServiceName:    acc.Name,
+		Addr:           DefaultObservabilityAddr,
+		Path:           DefaultObservabilityPath,
+		Ctx:            msCtx,
+		Health:         health,
+		Histograms:     mss.Metrics.Histograms,
+		Sinks:          mss.Metrics.Sinks,
+		MaxLabelValues: mss.Metrics.MaxLabelValues,
+		URIMapper:      uriMapper,
+
+		GaugeFuncInterval: mss.Metrics.GaugeFuncInterval,
+	}
+	ms, err := NewDefaultMetricsServer(msc)
+	if err != nil {
+		stopMetrics()
+		return nil, err
+	}
+	ac.ms = ms
+	ac.stopMetrics = stopMetrics
+	// stop the metrics server, and the exporters once tracing is set up,
+	// when the rest of the setup fails
+	fail := func(err error) (*applicationContext, error) {
+		if ac.tracing != nil {
+			ac.tracing.Shutdown(context.Background())
+		}
+		ac.shutdownMetrics(context.Background())
+		return nil, err
 	}
-	ms, _ := NewDefaultMetricsServer(msc)
 
-	ac := &applicationContext{
-		router: mux.NewRouter(),
-		logger: logger,
-		ms:     ms,
-		config: cfg,
+	// use configuration to setup logging
+	var lc LoggingConfig
+	if err := ac.GetConfig(&lc); err != nil {
+		return fail(err)
+	}
+	logger, err := logging.NewLeveledLogger(lc.Logging)
+	if err != nil {
+		return fail(err)
+	}
+	ac.logger = logger
+
+	als := AccessLogSettings{}
+	als.Logging.Access = DefaultAccessLogConfig()
+	if err := ac.GetConfig(&als); err != nil {
+		return fail(err)
+	}
+	ac.accessLog = als.Logging.Access
+
+	hcs := HTTPClientSettings{}
+	hcs.HTTP.Client = DefaultHTTPClientConfig()
+	if err := ac.GetConfig(&hcs); err != nil {
+		return fail(err)
+	}
+	ac.httpClient = hcs.HTTP.Client
+
+	ts := TracingSettings{Tracing: DefaultTracingConfig()}
+	if err := ac.GetConfig(&ts); err != nil {
+		return fail(err)
+	}
+	tracing, err := NewTracing(ctx, acc.Name, ts.Tracing)
+	if err != nil {
+		return fail(err)
+	}
+	ac.tracing = tracing
+
+	var ss ServicesSettings
+	if err := ac.GetConfig(&ss); err != nil {
+		return fail(err)
+	}
+	services, err := NewServiceRegistry(ss.Services, ac.NewHTTPClient)
+	if err != nil {
+		return fail(err)
+	}
+	ac.services = services
+	if lp, ok := logger.(logging.LevelsProvider); ok && lp.Levels() != nil {
+		h := LoggersHandler(DefaultLoggersPath, lp.Levels())
+		ms.Handle(DefaultLoggersPath, h)
+		ms.Handle(DefaultLoggersPath+"/", h)
 	}
 
 	// use configuration to setup server with TLS if necessary
 	var sc ServerConfig
 	if err := ac.GetConfig(&sc); err != nil {
-		return nil, err
+		return fail(err)
 	}
-	ac.server = server.NewServer(&sc.Server)
+	ac.server = newWebServer(sc.Server)
+
+	// the globals are only installed once nothing can fail anymore
+	tracing.SetGlobal()
 
 	return ac, nil
 }
@@ -97,6 +207,7 @@ func (ac *applicationContext) GetConfig(dest interface{}) error {
 	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
 		Result:           dest,
 		WeaklyTypedInput: true,
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
 	})
 	if err != nil {
 		return err
@@ -113,25 +224,198 @@ func (ac *applicationContext) GetRouter() (*mux.Router, error) {
 	return ac.router, nil
 }
 
-// Logger returns the ApplicationContext's logger
-func (ac *applicationContext) Logger() *slog.Logger {
+// Logger returns the ApplicationContext's logger, configured from
+// the `logging` block of the application's configuration
+func (ac *applicationContext) Logger() logging.LeveledLogger {
 	return ac.logger
 }
 
+// HTTPClientConfig returns the http client configuration read from
+// the `http.client` block of the application's configuration
+func (ac *applicationContext) HTTPClientConfig() HTTPClientConfig {
+	return ac.httpClient
+}
+
+// NewHTTPClient creates a client with the retry, backoff and circuit breaker
+// settings from the application's configuration. Requests are instrumented
+// by the ApplicationContext's metrics server and traced when tracing is enabled
+func (ac *applicationContext) NewHTTPClient() *http.Client {
+	return NewHTTPClient(ac.httpClient, ac.ms, ac.tracing.Transport(nil))
+}
+
+// Tracing returns the ApplicationContext's tracing, configured from
+// the `tracing` block of the application's configuration
+func (ac *applicationContext) Tracing() *Tracing {
+	return ac.tracing
+}
+
+// Services returns the registry of downstream Spinnaker services
+// configured under `services` in the application's configuration
+func (ac *applicationContext) Services() *ServiceRegistry {
+	return ac.services
+}
+
+// RegisterHealthIndicator adds a HealthIndicator to the application's health
+// report. Supplying LivenessGroup and/or ReadinessGroup also includes the
+// indicator in the corresponding Kubernetes probe endpoint
+func (ac *applicationContext) RegisterHealthIndicator(name string, indicator HealthIndicator, groups ...string) {
+	ac.health.Register(name, indicator, groups...)
+}
+
+// HealthRegistry returns the registry backing the application's health endpoints
+func (ac *applicationContext) HealthRegistry() *HealthRegistry {
+	return ac.health
+}
+
+// Register ties a component to the application's lifecycle. Components are
+// started by Start before the web server begins serving and are stopped in
+// reverse order during shutdown, once the web server has drained
+func (ac *applicationContext) Register(name string, component Lifecycle) {
+	ac.lifecycle.register(name, component)
+}
+
+// OnStop registers a hook that is run during shutdown, after the web server
+// and registered components have stopped and before the metrics server stops.
+// Hooks run in the order they were registered
+func (ac *applicationContext) OnStop(name string, hook StopHook) {
+	ac.lifecycle.onStop(name, hook)
+}
+
 // Start starts the ApplicationContext's web server and starts listening
-// on the configured port
+// on the configured port. Registered components are started first and, if any
+// of them fail, the error is returned without serving. When the server is
+// shut down, Start returns http.ErrServerClosed once the rest of the
+// application has finished stopping. If the server fails, the application
+// is shut down and any errors encountered while stopping it are returned
+// along with the failure
 func (ac *applicationContext) Start(router *mux.Router) error {
-	var r http.Handler
+	if err := ac.startComponents(); err != nil {
+		return err
+	}
+
+	ac.watchForShutdown()
+	err := ac.server.Start(ac.handler(router))
+	if err == http.ErrServerClosed {
+		<-ac.lifecycle.done
+		return err
+	}
+	return multierr.Append(err, ac.Shutdown(context.Background()))
+}
+
+// Run starts registered components, the web server and the metrics server, then
+// blocks until either server fails, a termination signal is received or ctx is
+// canceled. Everything is then shut down and any errors encountered while
+// running or stopping the application are returned combined. A clean shutdown
+// returns nil
+func (ac *applicationContext) Run(ctx context.Context) error {
+	if err := ac.startComponents(); err != nil {
+		return err
+	}
+
+	ac.watchForShutdown()
+	handler := ac.handler(nil)
+	errs := make(chan error, 2)
+	go func() {
+		errs <- ac.server.Start(handler)
+	}()
+	go func() {
+		errs <- ac.ms.Start()
+	}()
+
+	var runErr error
+	pending := 2
+	select {
+	case err := <-errs:
+		pending--
+		runErr = ignoreServerClosed(err)
+	case <-ctx.Done():
+	case <-ac.lifecycle.done:
+	}
+
+	shutdownErr := ac.Shutdown(context.Background())
+	for ; pending > 0; pending-- {
+		runErr = multierr.Append(runErr, ignoreServerClosed(<-errs))
+	}
+	return multierr.Append(runErr, shutdownErr)
+}
+
+// handler returns the instrumented handler served by the web server
+func (ac *applicationContext) handler(router *mux.Router) http.Handler {
 	if router == nil {
-		r = ac.router
+		router = ac.router
 	}
+	ac.ms.recordRouteTemplates(router)
+
+	var r http.Handler = router
+	// turn panics into 500 responses
+	r = RecoveryMiddleware(ac.logger, ac.ms)(r)
+	// make the logger available to handlers
+	r = LoggerMiddleware(ac.logger)(r)
+	// log http requests
+	r = AccessLogMiddleware(ac.accessLog, ac.logger.Named(AccessLoggerName))(r)
+	// carry the calling user's identity
+	r = SpinnakerAuthMiddleware(r)
+	// correlate requests between services
+	r = RequestIDMiddleware(r)
+	// continue the caller's trace
+	r = ac.tracing.Middleware(r)
 	// instrument http requests
-	r = ac.ms.RequestMetricsMiddleware(r)
-	return ac.server.Start(r)
+	return ac.ms.RequestMetricsMiddleware(r)
+}
+
+func ignoreServerClosed(err error) error {
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
 
 // CollectMetrics starts the ApplicationContext's metrics server
 func (ac *applicationContext) CollectMetrics() error {
-	go ac.ms.WatchForShutdown()
+	ac.watchForShutdown()
 	return ac.ms.Start()
 }
+
+// Shutdown gracefully stops the application. The web server is drained
+// first, then registered components are stopped and stop hooks are run,
+// pending spans are flushed and finally the metrics server is stopped so metrics remain available
+// for as long as possible. The whole sequence is bounded by the configured
+// shutdown timeout
+func (ac *applicationContext) Shutdown(ctx context.Context) error {
+	return ac.lifecycle.shutdown(ctx,
+		ac.shutdownServer,
+		ac.lifecycle.stopComponents(),
+		ac.lifecycle.stopHooks(),
+		ac.tracing.Shutdown,
+		ac.shutdownMetrics,
+	)
+}
+
+func (ac *applicationContext) startComponents() error {
+	if err := ac.lifecycle.startComponents(ac.ctx); err != nil {
+		ac.logger.Errorf("application failed to start: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (ac *applicationContext) watchForShutdown() {
+	ac.lifecycle.watch(ac.ctx, func() {
+		ac.logger.Infof("shutting down application")
+		if err := ac.Shutdown(context.Background()); err != nil {
+			ac.logger.Errorf("application did not shut down cleanly: %s", err.Error())
+		}
+	})
+}
+
+func (ac *applicationContext) shutdownServer(ctx context.Context) error {
+	return ac.server.Shutdown(ctx)
+}
+
+func (ac *applicationContext) shutdownMetrics(ctx context.Context) error {
+	if ac.ms == nil {
+		return nil
+	}
+	defer ac.stopMetrics()
+	return ac.ms.Shutdown(ctx)
+}
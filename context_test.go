@@ -1,8 +1,18 @@
 package go_spec
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/armory-io/go-spec/logging"
+	"github.com/armory/go-yaml-tools/pkg/tls/server"
+	"github.com/gorilla/mux"
+	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -39,3 +49,224 @@ func TestApplicationContext_GetConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestApplicationContext_GetLoggingConfig(t *testing.T) {
+	ac := &applicationContext{config: map[string]interface{}{
+		"logging": map[string]interface{}{
+			"json": map[string]interface{}{
+				"enabled": true,
+				"level":   "debug",
+				"fields": map[string]interface{}{
+					"time": "@timestamp",
+				},
+			},
+			"remote": map[string]interface{}{
+				"enabled":    "true",
+				"endpoint":   "http://sometest.com",
+				"customerId": "12345",
+			},
+			"levels": map[string]interface{}{
+				"com.armory.cache": "debug",
+			},
+		},
+	}}
+	var target LoggingConfig
+	if err := ac.GetConfig(&target); err != nil {
+		t.Fatalf("failed to convert config: %s", err.Error())
+	}
+	assert.EqualValues(t, logging.Config{
+		JSON: logging.FormatJson{
+			Enabled: true,
+			Level:   "debug",
+			Fields:  map[string]string{"time": "@timestamp"},
+		},
+		Remote: logging.RemoteLoggingConfig{
+			Enabled:    true,
+			Endpoint:   "http://sometest.com",
+			CustomerID: "12345",
+		},
+		Levels: map[string]string{"com.armory.cache": "debug"},
+	}, target.Logging)
+}
+
+func TestApplicationContext_GetHTTPClientConfig(t *testing.T) {
+	ac := &applicationContext{config: map[string]interface{}{
+		"http": map[string]interface{}{
+			"client": map[string]interface{}{
+				"timeout": "5s",
+				"retry": map[string]interface{}{
+					"maxAttempts": 5,
+				},
+				"circuitBreaker": map[string]interface{}{
+					"openDuration": "1m",
+				},
+			},
+		},
+	}}
+	target := HTTPClientSettings{}
+	target.HTTP.Client = DefaultHTTPClientConfig()
+	if err := ac.GetConfig(&target); err != nil {
+		t.Fatalf("failed to convert config: %s", err.Error())
+	}
+
+	expected := DefaultHTTPClientConfig()
+	expected.Timeout = 5 * time.Second
+	expected.Retry.MaxAttempts = 5
+	expected.CircuitBreaker.OpenDuration = time.Minute
+	assert.EqualValues(t, expected, target.HTTP.Client)
+}
+
+func TestApplicationContext_GetTracingConfig(t *testing.T) {
+	ac := &applicationContext{config: map[string]interface{}{
+		"tracing": map[string]interface{}{
+			"enabled":     "true",
+			"exporter":    "file",
+			"path":        "/tmp/spans.json",
+			"sampleRatio": "0.25",
+			"propagators": []interface{}{"b3multi"},
+		},
+	}}
+	target := TracingSettings{Tracing: DefaultTracingConfig()}
+	if err := ac.GetConfig(&target); err != nil {
+		t.Fatalf("failed to convert config: %s", err.Error())
+	}
+
+	expected := TracingConfig{
+		Enabled:     true,
+		Exporter:    FileExporter,
+		Path:        "/tmp/spans.json",
+		SampleRatio: 0.25,
+		Propagators: []string{"b3multi"},
+	}
+	assert.EqualValues(t, expected, target.Tracing)
+}
+
+func TestApplicationContext_RunStopsEarly(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer taken.Close()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := map[string]struct {
+		ctx         context.Context
+		metricsAddr string
+		expectedErr string
+	}{
+		"metrics server fails to start": {
+			ctx:         context.Background(),
+			metricsAddr: taken.Addr().String(),
+			expectedErr: "address already in use",
+		},
+		"canceled before serving": {
+			ctx:         canceled,
+			metricsAddr: "127.0.0.1:0",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			msCtx, stopMetrics := context.WithCancel(context.Background())
+			defer stopMetrics()
+			ms, err := NewDefaultMetricsServer(MetricsServerConfig{
+				ServiceName: "test",
+				Addr:        c.metricsAddr,
+				Ctx:         msCtx,
+				Registry:    prom.NewRegistry(),
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			tracing, err := NewTracing(context.Background(), "test", TracingConfig{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			logger, _ := newBufferedLogger(t)
+			ac := &applicationContext{
+				logger:      logger,
+				tracing:     tracing,
+				router:      mux.NewRouter(),
+				server:      newWebServer(server.ServerConfig{Host: "127.0.0.1"}),
+				ms:          ms,
+				ctx:         context.Background(),
+				lifecycle:   newLifecycle(time.Second),
+				stopMetrics: stopMetrics,
+			}
+
+			err = ac.Run(c.ctx)
+			if c.expectedErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), c.expectedErr)
+			}
+		})
+	}
+}
+
+func TestApplicationContext_StartFails(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer taken.Close()
+	addr := taken.Addr().(*net.TCPAddr)
+
+	ms, err := NewDefaultMetricsServer(MetricsServerConfig{ServiceName: "test", Registry: prom.NewRegistry()})
+	if !assert.NoError(t, err) {
+		return
+	}
+	tracing, err := NewTracing(context.Background(), "test", TracingConfig{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	logger, _ := newBufferedLogger(t)
+	ac := &applicationContext{
+		logger:      logger,
+		tracing:     tracing,
+		router:      mux.NewRouter(),
+		server:      newWebServer(server.ServerConfig{Host: addr.IP.String(), Port: uint32(addr.Port)}),
+		ms:          ms,
+		ctx:         context.Background(),
+		lifecycle:   newLifecycle(time.Second),
+		stopMetrics: func() {},
+	}
+	var events []string
+	ac.Register("cache", &testComponent{name: "cache", events: &events})
+
+	err = ac.Start(nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "address already in use")
+	}
+	assert.Equal(t, []string{"start cache", "stop cache"}, events)
+	select {
+	case <-ac.lifecycle.done:
+	default:
+		t.Error("the application was not shut down")
+	}
+}
+
+func TestApplicationContext_HandlerInstallsRouteTemplatesOnce(t *testing.T) {
+	runs := countRouteTemplates(t)
+	ms, sink := newTestMetricsServer(t)
+	tracing, err := NewTracing(context.Background(), "test", TracingConfig{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	logger, _ := newBufferedLogger(t)
+	ac := &applicationContext{logger: logger, tracing: tracing, ms: ms, router: mux.NewRouter()}
+	ac.router.HandleFunc("/applications/{name}", func(w http.ResponseWriter, r *http.Request) {})
+
+	ac.handler(nil)
+	ac.handler(ac.router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/applications/deck", nil))
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+	var uris []string
+	for _, interval := range sink.Data() {
+		for _, sample := range interval.Samples {
+			uris = append(uris, labelValue(sample.Labels, "uri"))
+		}
+	}
+	assert.Equal(t, []string{"/applications/{name}"}, uris)
+}
@@ -0,0 +1,133 @@
+package go_spec
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/armory-io/go-spec/logging"
+)
+
+// ErrorResponse is the body of error responses, in the shape
+// returned by Spring's default error handling
+type ErrorResponse struct {
+	Timestamp time.Time `json:"timestamp"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error"`
+	Message   string    `json:"message"`
+	Path      string    `json:"path"`
+}
+
+// StatusError is implemented by errors that are rendered with a specific
+// status code. Its Reason, or its message when it has none, is returned
+// to the caller
+type StatusError interface {
+	error
+	StatusCode() int
+}
+
+// ResponseError is an error rendered with a specific status code. The
+// Message is returned to the caller while the Cause is only logged, at the
+// error level for 5xx responses and at the info level otherwise
+type ResponseError struct {
+	Status  int
+	Message string
+	Cause   error
+}
+
+func (e *ResponseError) Error() string {
+	if e.Cause == nil {
+		return e.Message
+	}
+	if e.Message == "" {
+		return e.Cause.Error()
+	}
+	return e.Message + ": " + e.Cause.Error()
+}
+
+func (e *ResponseError) Unwrap() error   { return e.Cause }
+func (e *ResponseError) StatusCode() int { return e.Status }
+
+// Reason returns the message returned to the caller
+func (e *ResponseError) Reason() string { return e.Message }
+
+// NotFoundError is rendered as a 404
+type NotFoundError struct{ ResponseError }
+
+// NewNotFoundError creates a NotFoundError, cause may be nil
+func NewNotFoundError(message string, cause error) *NotFoundError {
+	return &NotFoundError{ResponseError{Status: http.StatusNotFound, Message: message, Cause: cause}}
+}
+
+// BadRequestError is rendered as a 400
+type BadRequestError struct{ ResponseError }
+
+// NewBadRequestError creates a BadRequestError, cause may be nil
+func NewBadRequestError(message string, cause error) *BadRequestError {
+	return &BadRequestError{ResponseError{Status: http.StatusBadRequest, Message: message, Cause: cause}}
+}
+
+// ForbiddenError is rendered as a 403
+type ForbiddenError struct{ ResponseError }
+
+// NewForbiddenError creates a ForbiddenError, cause may be nil
+func NewForbiddenError(message string, cause error) *ForbiddenError {
+	return &ForbiddenError{ResponseError{Status: http.StatusForbidden, Message: message, Cause: cause}}
+}
+
+// ConflictError is rendered as a 409
+type ConflictError struct{ ResponseError }
+
+// NewConflictError creates a ConflictError, cause may be nil
+func NewConflictError(message string, cause error) *ConflictError {
+	return &ConflictError{ResponseError{Status: http.StatusConflict, Message: message, Cause: cause}}
+}
+
+// HandlerFunc is a handler that returns errors instead of writing them,
+// leaving WriteError to render the response
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (f HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
+
+// WriteError responds to r with an ErrorResponse. When err is, or wraps, a
+// StatusError its status code and reason are used, and the error is logged
+// when it is a server error or has a cause. Any other error is logged and
+// returned as a 500 without exposing its message. The error's type is
+// reported as the exception label of the request's metrics
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	logger := logging.FromContext(r.Context())
+	var se StatusError
+	if !errors.As(err, &se) {
+		logger.Errorf("error serving %s %s: %s", r.Method, r.URL.Path, err.Error())
+		setException(r, exceptionName(err))
+		writeError(w, r, http.StatusInternalServerError, "")
+		return
+	}
+	setException(r, exceptionName(se))
+	switch {
+	case se.StatusCode() >= 500:
+		logger.Errorf("error serving %s %s: %s", r.Method, r.URL.Path, err.Error())
+	case errors.Unwrap(se) != nil:
+		logger.Infof("error serving %s %s: %s", r.Method, r.URL.Path, err.Error())
+	}
+	message := se.Error()
+	if rs, ok := se.(interface{ Reason() string }); ok {
+		message = rs.Reason()
+	}
+	writeError(w, r, se.StatusCode(), message)
+}
+
+// writeError responds to r with code and an ErrorResponse carrying message
+func writeError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	writeJSON(w, code, ErrorResponse{
+		Timestamp: time.Now().UTC(),
+		Status:    code,
+		Error:     http.StatusText(code),
+		Message:   message,
+		Path:      r.URL.Path,
+	})
+}
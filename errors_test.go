@@ -0,0 +1,107 @@
+package go_spec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armory-io/go-spec/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerFunc(t *testing.T) {
+	cases := map[string]struct {
+		err               error
+		expectedStatus    int
+		expectedMessage   string
+		expectedException string
+		expectedLog       string
+	}{
+		"not found": {
+			err:               NewNotFoundError("application deck not found", nil),
+			expectedStatus:    http.StatusNotFound,
+			expectedMessage:   "application deck not found",
+			expectedException: "NotFoundError",
+		},
+		"bad request hides its cause": {
+			err:               NewBadRequestError("invalid application name", errors.New("regexp mismatch")),
+			expectedStatus:    http.StatusBadRequest,
+			expectedMessage:   "invalid application name",
+			expectedException: "BadRequestError",
+			expectedLog:       "level=info msg=\"error serving GET /applications/deck: invalid application name: regexp mismatch\"",
+		},
+		"forbidden": {
+			err:               NewForbiddenError("access denied", nil),
+			expectedStatus:    http.StatusForbidden,
+			expectedMessage:   "access denied",
+			expectedException: "ForbiddenError",
+		},
+		"wrapped conflict": {
+			err:               fmt.Errorf("saving: %w", NewConflictError("application exists", nil)),
+			expectedStatus:    http.StatusConflict,
+			expectedMessage:   "application exists",
+			expectedException: "ConflictError",
+		},
+		"custom status error": {
+			err:               &ResponseError{Status: http.StatusTooManyRequests, Message: "slow down"},
+			expectedStatus:    http.StatusTooManyRequests,
+			expectedMessage:   "slow down",
+			expectedException: "ResponseError",
+		},
+		"server error": {
+			err:               &ResponseError{Status: http.StatusBadGateway, Message: "front50 unavailable"},
+			expectedStatus:    http.StatusBadGateway,
+			expectedMessage:   "front50 unavailable",
+			expectedException: "ResponseError",
+			expectedLog:       "level=error msg=\"error serving GET /applications/deck: front50 unavailable\"",
+		},
+		"unknown error": {
+			err:               errors.New("database unavailable"),
+			expectedStatus:    http.StatusInternalServerError,
+			expectedMessage:   "",
+			expectedException: "errorString",
+			expectedLog:       "level=error msg=\"error serving GET /applications/deck: database unavailable\"",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			h := HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+				return c.err
+			})
+			logger, buf := newBufferedLogger(t)
+			req := httptest.NewRequest(http.MethodGet, "/applications/deck", nil)
+			req = req.WithContext(logging.IntoContext(req.Context(), logger))
+			req, info := withRequestInfo(req)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, c.expectedStatus, rec.Code)
+			var body ErrorResponse
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			assert.Equal(t, c.expectedStatus, body.Status)
+			assert.Equal(t, http.StatusText(c.expectedStatus), body.Error)
+			assert.Equal(t, c.expectedMessage, body.Message)
+			assert.Equal(t, "/applications/deck", body.Path)
+			assert.Equal(t, c.expectedException, info.exception)
+			if c.expectedLog == "" {
+				assert.Empty(t, buf.String())
+			} else {
+				assert.Contains(t, buf.String(), c.expectedLog)
+			}
+		})
+	}
+}
+
+func TestResponseError(t *testing.T) {
+	cause := errors.New("not in cache")
+	err := NewNotFoundError("application deck not found", cause)
+	assert.Equal(t, "application deck not found: not in cache", err.Error())
+	assert.True(t, errors.Is(err, cause))
+
+	var nf *NotFoundError
+	assert.True(t, errors.As(fmt.Errorf("loading: %w", err), &nf))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -11,6 +12,7 @@ import (
 func main() {
 	// Apply any application specific configuration
 	appConfig := spec.ApplicationContextConfig{
+		Name:        "basicapp",
 		ConfigNames: []string{"spinnaker", "basicapp"},
 	}
 
@@ -25,12 +27,6 @@ func main() {
 	// logger is a standardized logger
 	logger := appContext.Logger()
 
-	go func() {
-		if err := appContext.CollectMetrics(); err != nil {
-			logger.Fatalf("metrics collection failed: %s", err.Error())
-		}
-	}()
-
 	// using the ApplicationContext's router ensures that our routes are logged
 	// and instrumented properly
 	router, _ := appContext.GetRouter()
@@ -38,8 +34,11 @@ func main() {
 		w.Write([]byte("world"))
 	})
 
+	// Run starts both the application and metrics servers and blocks
+	// until the application is shut down, either by a signal or
+	// because one of the servers failed
 	logger.Infof("starting basic application")
-	if err := appContext.Start(nil); err != http.ErrServerClosed {
+	if err := appContext.Run(context.Background()); err != nil {
 		logger.Fatalf("application failed unexpectedly: %s", err.Error())
 	}
 	logger.Infof("basic application exiting")
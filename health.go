@@ -0,0 +1,172 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+var (
+	DefaultHealthPath = "/armory-observability/health"
+)
+
+// Status describes the state of a component or of the application
+// as a whole. Values mirror the ones used by Spring Boot's actuator
+type Status string
+
+const (
+	StatusUp           Status = "UP"
+	StatusDown         Status = "DOWN"
+	StatusOutOfService Status = "OUT_OF_SERVICE"
+	StatusUnknown      Status = "UNKNOWN"
+)
+
+// statusOrder is used when aggregating statuses, the first status in
+// the list that is reported by any component wins
+var statusOrder = []Status{StatusDown, StatusOutOfService, StatusUp, StatusUnknown}
+
+// Health groups that are mounted on the metrics server so Kubernetes
+// probes can target them independently of the full health report
+const (
+	LivenessGroup  = "liveness"
+	ReadinessGroup = "readiness"
+)
+
+// Health is the result of a single HealthIndicator check
+type Health struct {
+	Status  Status                 `json:"status"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// HealthIndicator reports the health of a single component of the application
+type HealthIndicator interface {
+	Health(ctx context.Context) Health
+}
+
+// HealthIndicatorFunc allows plain functions to be used as a HealthIndicator
+type HealthIndicatorFunc func(ctx context.Context) Health
+
+func (f HealthIndicatorFunc) Health(ctx context.Context) Health {
+	return f(ctx)
+}
+
+// CompositeHealth is the aggregated result of running a set of HealthIndicators
+type CompositeHealth struct {
+	Status     Status            `json:"status"`
+	Components map[string]Health `json:"components,omitempty"`
+}
+
+type registeredIndicator struct {
+	indicator HealthIndicator
+	groups    map[string]bool
+}
+
+// HealthRegistry holds the HealthIndicators registered by the application
+// and aggregates them into a single status
+type HealthRegistry struct {
+	mu         sync.RWMutex
+	indicators map[string]registeredIndicator
+}
+
+// NewHealthRegistry creates an empty HealthRegistry
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{
+		indicators: map[string]registeredIndicator{},
+	}
+}
+
+// Register adds a HealthIndicator under the given name. Indicators are always part
+// of the full health report and are additionally included in any groups supplied
+func (hr *HealthRegistry) Register(name string, indicator HealthIndicator, groups ...string) {
+	g := map[string]bool{}
+	for _, group := range groups {
+		g[group] = true
+	}
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.indicators[name] = registeredIndicator{indicator: indicator, groups: g}
+}
+
+// Unregister removes the HealthIndicator with the given name
+func (hr *HealthRegistry) Unregister(name string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	delete(hr.indicators, name)
+}
+
+// Health runs every registered indicator and aggregates the results
+func (hr *HealthRegistry) Health(ctx context.Context) CompositeHealth {
+	return hr.check(ctx, "")
+}
+
+// GroupHealth runs the indicators belonging to group and aggregates the results.
+// An empty group is reported as UP so probes succeed until indicators are added
+func (hr *HealthRegistry) GroupHealth(ctx context.Context, group string) CompositeHealth {
+	return hr.check(ctx, group)
+}
+
+func (hr *HealthRegistry) check(ctx context.Context, group string) CompositeHealth {
+	hr.mu.RLock()
+	names := make([]string, 0, len(hr.indicators))
+	indicators := map[string]HealthIndicator{}
+	for name, ri := range hr.indicators {
+		if group != "" && !ri.groups[group] {
+			continue
+		}
+		names = append(names, name)
+		indicators[name] = ri.indicator
+	}
+	hr.mu.RUnlock()
+	sort.Strings(names)
+
+	components := map[string]Health{}
+	statuses := []Status{}
+	for _, name := range names {
+		h := indicators[name].Health(ctx)
+		if h.Status == "" {
+			h.Status = StatusUnknown
+		}
+		components[name] = h
+		statuses = append(statuses, h.Status)
+	}
+	return CompositeHealth{
+		Status:     aggregateStatus(statuses),
+		Components: components,
+	}
+}
+
+func aggregateStatus(statuses []Status) Status {
+	if len(statuses) == 0 {
+		return StatusUp
+	}
+	seen := map[Status]bool{}
+	for _, s := range statuses {
+		seen[s] = true
+	}
+	for _, s := range statusOrder {
+		if seen[s] {
+			return s
+		}
+	}
+	return StatusUnknown
+}
+
+// statusToHTTPCode maps an aggregated status to the response code
+// returned by the health endpoints
+func statusToHTTPCode(s Status) int {
+	switch s {
+	case StatusDown, StatusOutOfService:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// HealthHandler serves the aggregated health of the registry, or of a
+// single group when group is non-empty
+func (hr *HealthRegistry) HealthHandler(group string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ch := hr.check(r.Context(), group)
+		writeJSON(w, statusToHTTPCode(ch.Status), ch)
+	})
+}
@@ -0,0 +1,87 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func staticHealth(s Status) HealthIndicator {
+	return HealthIndicatorFunc(func(ctx context.Context) Health {
+		return Health{Status: s}
+	})
+}
+
+func TestHealthRegistry_Health(t *testing.T) {
+	cases := map[string]struct {
+		indicators     map[string]Status
+		expectedStatus Status
+	}{
+		"no indicators is up": {
+			indicators:     map[string]Status{},
+			expectedStatus: StatusUp,
+		},
+		"all up": {
+			indicators:     map[string]Status{"db": StatusUp, "cache": StatusUp},
+			expectedStatus: StatusUp,
+		},
+		"down wins over out of service": {
+			indicators:     map[string]Status{"db": StatusDown, "cache": StatusOutOfService, "queue": StatusUp},
+			expectedStatus: StatusDown,
+		},
+		"out of service wins over up": {
+			indicators:     map[string]Status{"db": StatusOutOfService, "cache": StatusUp},
+			expectedStatus: StatusOutOfService,
+		},
+		"missing status is unknown": {
+			indicators:     map[string]Status{"db": ""},
+			expectedStatus: StatusUnknown,
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			hr := NewHealthRegistry()
+			for name, s := range c.indicators {
+				hr.Register(name, staticHealth(s))
+			}
+			h := hr.Health(context.Background())
+			assert.Equal(t, c.expectedStatus, h.Status)
+			assert.Len(t, h.Components, len(c.indicators))
+		})
+	}
+}
+
+func TestHealthRegistry_HealthHandler(t *testing.T) {
+	hr := NewHealthRegistry()
+	hr.Register("db", staticHealth(StatusDown), ReadinessGroup)
+	hr.Register("ping", staticHealth(StatusUp), LivenessGroup)
+
+	cases := map[string]struct {
+		group        string
+		expectedCode int
+	}{
+		"full report includes failing indicator": {
+			group:        "",
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"liveness excludes readiness indicators": {
+			group:        LivenessGroup,
+			expectedCode: http.StatusOK,
+		},
+		"readiness reports failing indicator": {
+			group:        ReadinessGroup,
+			expectedCode: http.StatusServiceUnavailable,
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			hr.HealthHandler(c.group).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, c.expectedCode, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package go_spec
+
+import (
+	"sort"
+)
+
+// DefaultHistogramBuckets are the bucket boundaries, in milliseconds, used
+// for timers when a HistogramConfig doesn't declare any
+var DefaultHistogramBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// HistogramConfig exposes the timers and samples whose name starts with Prefix,
+// e.g. "http.server.requests", as Prometheus histograms rather than summaries
+// so that they can be aggregated across instances
+type HistogramConfig struct {
+	Prefix string `yaml:"prefix"`
+	// Buckets are the upper bounds of the histogram's buckets, in the unit the
+	// samples are recorded in. Timers are recorded in milliseconds
+	Buckets []float64 `yaml:"buckets"`
+	// SLOs are service level objectives, e.g. 100 for requests served within
+	// 100ms. Each one is added as a bucket boundary so that the ratio of
+	// samples meeting it can be computed exactly
+	SLOs []float64 `yaml:"slos"`
+	// Percentiles, e.g. 0.99, are additionally computed by each instance and
+	// exposed as a summary suffixed with _percentiles. Unlike the histogram,
+	// they can't be aggregated across instances
+	Percentiles []float64 `yaml:"percentiles"`
+}
+
+// DefaultHistograms exposes the server and client request timers as histograms
+func DefaultHistograms() []HistogramConfig {
+	return []HistogramConfig{
+		{Prefix: "http.server.requests"},
+		{Prefix: "http.client.requests"},
+	}
+}
+
+// buckets returns the sorted bucket boundaries, including SLOs
+func (hc HistogramConfig) buckets() []float64 {
+	buckets := hc.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	seen := map[float64]bool{}
+	merged := []float64{}
+	for _, b := range append(append([]float64{}, buckets...), hc.SLOs...) {
+		if !seen[b] {
+			seen[b] = true
+			merged = append(merged, b)
+		}
+	}
+	sort.Float64s(merged)
+	return merged
+}
@@ -0,0 +1,128 @@
+package go_spec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/armon/go-metrics/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramConfig_Buckets(t *testing.T) {
+	cases := map[string]struct {
+		cfg      HistogramConfig
+		expected []float64
+	}{
+		"defaults": {
+			cfg:      HistogramConfig{},
+			expected: DefaultHistogramBuckets,
+		},
+		"slos are merged": {
+			cfg:      HistogramConfig{Buckets: []float64{100, 10, 1000}, SLOs: []float64{200, 100}},
+			expected: []float64{10, 100, 200, 1000},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.expected, c.cfg.buckets())
+		})
+	}
+}
+
+func TestHistogramSink(t *testing.T) {
+	reg := prom.NewRegistry()
+	next, err := prometheus.NewPrometheusSinkFrom(prometheus.PrometheusOpts{Registerer: reg})
+	assert.NoError(t, err)
+	sink, err := newPrometheusSink(next, "app", []HistogramConfig{
+		{Prefix: "http.server", Buckets: []float64{100}},
+		{Prefix: "http.server.requests", Buckets: []float64{10, 1000}, SLOs: []float64{250}, Percentiles: []float64{0.99}},
+	}, nil, reg)
+	assert.NoError(t, err)
+
+	cfg := metrics.DefaultConfig("app")
+	cfg.EnableHostname = false
+	cfg.EnableRuntimeMetrics = false
+	m, err := metrics.New(cfg, sink)
+	assert.NoError(t, err)
+
+	labels := []metrics.Label{{Name: "uri", Value: "/applications/{name}"}}
+	m.AddSampleWithLabels([]string{"http.server.requests"}, 50, labels)
+	m.AddSampleWithLabels([]string{"http.server.requests"}, 500, labels)
+	m.AddSampleWithLabels([]string{"queue.latency"}, 50, nil)
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	byName := map[string]*dto.MetricFamily{}
+	for _, f := range families {
+		byName[f.GetName()] = f
+	}
+
+	requests := byName["app_http_server_requests"]
+	if assert.NotNil(t, requests) {
+		assert.Equal(t, dto.MetricType_HISTOGRAM, requests.GetType())
+		h := requests.GetMetric()[0].GetHistogram()
+		assert.Equal(t, uint64(2), h.GetSampleCount())
+		bounds := []float64{}
+		counts := []uint64{}
+		for _, b := range h.GetBucket() {
+			bounds = append(bounds, b.GetUpperBound())
+			counts = append(counts, b.GetCumulativeCount())
+		}
+		assert.Equal(t, []float64{10, 250, 1000}, bounds)
+		assert.Equal(t, []uint64{0, 1, 2}, counts)
+	}
+	percentiles := byName["app_http_server_requests_percentiles"]
+	if assert.NotNil(t, percentiles) {
+		assert.Equal(t, dto.MetricType_SUMMARY, percentiles.GetType())
+	}
+	latency := byName["app_queue_latency"]
+	if assert.NotNil(t, latency) {
+		assert.Equal(t, dto.MetricType_SUMMARY, latency.GetType())
+	}
+}
+
+func TestHistogramSink_Expiration(t *testing.T) {
+	sink, err := newPrometheusSink(&metrics.BlackholeSink{}, "app", DefaultHistograms(), nil, prom.NewRegistry())
+	assert.NoError(t, err)
+	sink.AddSample([]string{"app", "http.client.requests"}, 1)
+	sink.series["app_http_client_requests"].updatedAt = time.Now().Add(-2 * sink.expiration)
+
+	c := make(chan prom.Metric, 10)
+	sink.Collect(c)
+	assert.Len(t, c, 0)
+	assert.Empty(t, sink.series)
+}
+
+func TestHistogramSink_SlowScrape(t *testing.T) {
+	sink, err := newPrometheusSink(&metrics.BlackholeSink{}, "app", DefaultHistograms(), nil, prom.NewRegistry())
+	assert.NoError(t, err)
+	sink.AddSample([]string{"app", "http.server.requests"}, 1)
+	sink.AddSample([]string{"app", "http.client.requests"}, 1)
+
+	// the scraper stalls after reading the first series
+	c := make(chan prom.Metric)
+	go func() {
+		sink.Collect(c)
+		close(c)
+	}()
+	<-c
+	defer func() {
+		for range c {
+		}
+	}()
+
+	recorded := make(chan struct{})
+	go func() {
+		sink.AddSample([]string{"app", "http.server.requests.active"}, 1)
+		close(recorded)
+	}()
+	select {
+	case <-recorded:
+	case <-time.After(time.Second):
+		t.Fatal("recording a sample waited on the scrape")
+	}
+}
@@ -0,0 +1,326 @@
+package go_spec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+)
+
+// ErrCircuitOpen is returned by clients created with NewHTTPClient when
+// requests to a host are rejected because its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// HTTPClientConfig configures the clients created by NewHTTPClient. The
+// ApplicationContext reads it from the `http.client` block of the
+// application's configuration
+type HTTPClientConfig struct {
+	// Timeout bounds the total time of a request, including retries
+	Timeout        time.Duration        `yaml:"timeout"`
+	Retry          RetryConfig          `yaml:"retry"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker"`
+}
+
+// RetryConfig configures retries of idempotent requests that fail with a
+// connection error or a 5xx response
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts made, including the
+	// first one. A value of 1 or less disables retries
+	MaxAttempts int `yaml:"maxAttempts"`
+	// InitialBackoff is the upper bound of the delay before the first retry,
+	// it grows by Multiplier for each subsequent retry up to MaxBackoff. The
+	// actual delay is chosen at random below the bound
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker kept for each host
+type CircuitBreakerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// FailureThreshold is the number of consecutive failures that opens the breaker
+	FailureThreshold int `yaml:"failureThreshold"`
+	// OpenDuration is how long the breaker rejects requests before letting
+	// a single trial request through
+	OpenDuration time.Duration `yaml:"openDuration"`
+}
+
+// DefaultHTTPClientConfig retries idempotent requests up to 3 times
+// and opens a host's breaker after 5 consecutive failures
+func DefaultHTTPClientConfig() HTTPClientConfig {
+	return HTTPClientConfig{
+		Timeout: 30 * time.Second,
+		Retry: RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     2 * time.Second,
+			Multiplier:     2,
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			Enabled:          true,
+			FailureThreshold: 5,
+			OpenDuration:     30 * time.Second,
+		},
+	}
+}
+
+// HTTPClientSettings is used to extract the http client
+// configuration from the application's configuration
+type HTTPClientSettings struct {
+	HTTP struct {
+		Client HTTPClientConfig `yaml:"client"`
+	} `yaml:"http"`
+}
+
+// NewHTTPClient creates a client that retries idempotent requests with jittered
+// exponential backoff and stops calling hosts that keep failing. Each attempt
+// is recorded in the http.client.requests timer, while attempts, retries and
+// circuit breaker state changes are recorded through the metrics server's
+// registry. ms may be nil, in which case nothing is recorded. The request ID
+// and Spinnaker identity stored in a request's context are forwarded
+func NewHTTPClient(cfg HTTPClientConfig, ms *MetricsServer, next http.RoundTripper) *http.Client {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	next = SpinnakerAuthTransport(RequestIDTransport(next))
+	var m *metrics.Metrics
+	var defaultLabels []metrics.Label
+	if ms != nil {
+		next = ms.InstrumentTransport(next)
+		m = ms.metrics
+		defaultLabels = ms.defaultLabels
+	}
+	rt := &resilientTransport{
+		cfg:           cfg,
+		next:          next,
+		metrics:       m,
+		defaultLabels: defaultLabels,
+		breakers:      map[string]*circuitBreaker{},
+		jitter:        rand.Float64,
+	}
+	return &http.Client{Transport: rt, Timeout: cfg.Timeout}
+}
+
+type resilientTransport struct {
+	cfg           HTTPClientConfig
+	next          http.RoundTripper
+	metrics       *metrics.Metrics
+	defaultLabels []metrics.Label
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	jitter   func() float64
+}
+
+func (rt *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	maxAttempts := rt.cfg.Retry.MaxAttempts
+	if maxAttempts < 1 || !retryable(req) {
+		maxAttempts = 1
+	}
+	labels := append([]metrics.Label{
+		{Name: "method", Value: req.Method},
+		{Name: "clientName", Value: req.URL.Hostname()},
+	}, rt.defaultLabels...)
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(req.Context())
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		rt.incr("http.client.attempts", labels)
+		resp, err := rt.attempt(attemptReq)
+		if attempt >= maxAttempts || !shouldRetry(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			// the response is discarded, release the connection
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(rt.backoff(attempt)):
+		}
+		rt.incr("http.client.retries", labels)
+	}
+}
+
+// attempt sends a single request through the host's circuit breaker
+func (rt *resilientTransport) attempt(req *http.Request) (*http.Response, error) {
+	if !rt.cfg.CircuitBreaker.Enabled {
+		return rt.next.RoundTrip(req)
+	}
+	cb := rt.breaker(req.URL.Host)
+	if !cb.allow() {
+		return nil, fmt.Errorf("request to %s rejected: %w", req.URL.Host, ErrCircuitOpen)
+	}
+	resp, err := rt.next.RoundTrip(req)
+	cb.record(err == nil && resp.StatusCode < 500)
+	return resp, err
+}
+
+func (rt *resilientTransport) breaker(host string) *circuitBreaker {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	cb, ok := rt.breakers[host]
+	if !ok {
+		cb = &circuitBreaker{
+			cfg: rt.cfg.CircuitBreaker,
+			onTransition: func(from, to breakerState) {
+				rt.recordTransition(host, from, to)
+			},
+		}
+		rt.breakers[host] = cb
+	}
+	return cb
+}
+
+// backoff returns a random delay below the exponentially growing bound
+// for the given attempt, i.e. "full jitter"
+func (rt *resilientTransport) backoff(attempt int) time.Duration {
+	r := rt.cfg.Retry
+	multiplier := r.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	bound := float64(r.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if r.MaxBackoff > 0 && bound > float64(r.MaxBackoff) {
+		bound = float64(r.MaxBackoff)
+	}
+	return time.Duration(rt.jitter() * bound)
+}
+
+func (rt *resilientTransport) incr(name string, labels []metrics.Label) {
+	if rt.metrics != nil {
+		rt.metrics.IncrCounterWithLabels([]string{name}, 1, labels)
+	}
+}
+
+func (rt *resilientTransport) recordTransition(host string, from, to breakerState) {
+	if rt.metrics == nil {
+		return
+	}
+	labels := append([]metrics.Label{
+		{Name: "clientName", Value: host},
+		{Name: "from", Value: from.String()},
+		{Name: "to", Value: to.String()},
+	}, rt.defaultLabels...)
+	rt.metrics.IncrCounterWithLabels([]string{"http.client.circuitbreaker.transitions"}, 1, labels)
+	stateLabels := append([]metrics.Label{{Name: "clientName", Value: host}}, rt.defaultLabels...)
+	rt.metrics.SetGaugeWithLabels([]string{"http.client.circuitbreaker.state"}, float32(to), stateLabels)
+}
+
+// retryable reports whether req can safely be sent more than once
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen)
+	}
+	return resp.StatusCode >= 500
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "OPEN"
+	case breakerHalfOpen:
+		return "HALF_OPEN"
+	}
+	return "CLOSED"
+}
+
+// circuitBreaker stops requests to a host after consecutive failures. Once
+// open, a single trial request is let through after OpenDuration, closing the
+// breaker if it succeeds and opening it again if it fails
+type circuitBreaker struct {
+	cfg          CircuitBreakerConfig
+	onTransition func(from, to breakerState)
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func (cb *circuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case breakerOpen:
+		if time.Since(cb.openedAt) < cb.cfg.OpenDuration {
+			return false
+		}
+		cb.transition(breakerHalfOpen)
+		cb.trial = true
+		return true
+	case breakerHalfOpen:
+		// only a single trial request is allowed at a time
+		if cb.trial {
+			return false
+		}
+		cb.trial = true
+		return true
+	}
+	return true
+}
+
+func (cb *circuitBreaker) record(success bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.trial = false
+	if success {
+		cb.failures = 0
+		if cb.state != breakerClosed {
+			cb.transition(breakerClosed)
+		}
+		return
+	}
+	cb.failures++
+	if cb.state == breakerHalfOpen || (cb.state == breakerClosed && cb.failures >= cb.cfg.FailureThreshold) {
+		cb.openedAt = time.Now()
+		cb.transition(breakerOpen)
+	}
+}
+
+// transition must be called while holding cb.mu
+func (cb *circuitBreaker) transition(to breakerState) {
+	from := cb.state
+	cb.state = to
+	if cb.onTransition != nil {
+		cb.onTransition(from, to)
+	}
+}
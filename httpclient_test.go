@@ -0,0 +1,168 @@
+package go_spec
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestMetricsServer creates a MetricsServer that records
+// into an in-memory sink which can be inspected by tests
+func newTestMetricsServer(t *testing.T) (*MetricsServer, *metrics.InmemSink) {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	cfg := metrics.DefaultConfig("")
+	cfg.EnableHostname = false
+	cfg.EnableRuntimeMetrics = false
+	m, err := metrics.New(cfg, sink)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return &MetricsServer{metrics: m, meters: newMeterRegistry()}, sink
+}
+
+// counterTotal sums a counter across all of its label combinations
+func counterTotal(sink *metrics.InmemSink, name string) int {
+	total := 0
+	for _, interval := range sink.Data() {
+		for _, c := range interval.Counters {
+			if c.Name == name {
+				total += c.Count
+			}
+		}
+	}
+	return total
+}
+
+func testClientConfig() HTTPClientConfig {
+	cfg := DefaultHTTPClientConfig()
+	cfg.Retry.InitialBackoff = time.Millisecond
+	cfg.Retry.MaxBackoff = time.Millisecond
+	return cfg
+}
+
+func TestNewHTTPClient_Retries(t *testing.T) {
+	cases := map[string]struct {
+		method           string
+		body             string
+		noBody           bool
+		failures         int32
+		expectedStatus   int
+		expectedAttempts int32
+	}{
+		"retries idempotent request until it succeeds": {
+			method:           http.MethodGet,
+			failures:         2,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 3,
+		},
+		"retries request without body": {
+			method:           http.MethodGet,
+			noBody:           true,
+			failures:         1,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		"replays request body": {
+			method:           http.MethodPut,
+			body:             "payload",
+			failures:         1,
+			expectedStatus:   http.StatusOK,
+			expectedAttempts: 2,
+		},
+		"gives up after max attempts": {
+			method:           http.MethodGet,
+			failures:         5,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 3,
+		},
+		"does not retry non-idempotent request": {
+			method:           http.MethodPost,
+			body:             "payload",
+			failures:         1,
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedAttempts: 1,
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				body, _ := ioutil.ReadAll(r.Body)
+				assert.Equal(t, c.body, string(body))
+				if n <= c.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+				}
+			}))
+			defer server.Close()
+
+			ms, sink := newTestMetricsServer(t)
+			client := NewHTTPClient(testClientConfig(), ms, nil)
+			var body io.Reader
+			if !c.noBody {
+				body = strings.NewReader(c.body)
+			}
+			req, _ := http.NewRequest(c.method, server.URL, body)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err.Error())
+			}
+			resp.Body.Close()
+
+			assert.Equal(t, c.expectedStatus, resp.StatusCode)
+			assert.Equal(t, c.expectedAttempts, atomic.LoadInt32(&attempts))
+			assert.Equal(t, int(c.expectedAttempts), counterTotal(sink, "http.client.attempts"))
+			assert.Equal(t, int(c.expectedAttempts-1), counterTotal(sink, "http.client.retries"))
+		})
+	}
+}
+
+func TestNewHTTPClient_CircuitBreaker(t *testing.T) {
+	var healthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&healthy) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	cfg := testClientConfig()
+	cfg.Retry.MaxAttempts = 1
+	cfg.CircuitBreaker.FailureThreshold = 2
+	cfg.CircuitBreaker.OpenDuration = 20 * time.Millisecond
+	ms, sink := newTestMetricsServer(t)
+	client := NewHTTPClient(cfg, ms, nil)
+
+	get := func() (*http.Response, error) {
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := get()
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	}
+	_, err := get()
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+
+	// once the open duration elapses a trial request closes the breaker
+	atomic.StoreInt32(&healthy, 1)
+	time.Sleep(30 * time.Millisecond)
+	resp, err := get()
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 3, counterTotal(sink, "http.client.circuitbreaker.transitions"))
+}
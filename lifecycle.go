@@ -0,0 +1,210 @@
+package go_spec
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+var (
+	DefaultShutdownTimeout = 30 * time.Second
+)
+
+// StopHook is run while the ApplicationContext is shutting down. The supplied
+// context is canceled once the total shutdown timeout elapses
+type StopHook func(ctx context.Context) error
+
+type namedStopHook struct {
+	name string
+	hook StopHook
+}
+
+// Lifecycle is implemented by components whose execution is tied to the
+// ApplicationContext, such as queue consumers, pollers and caches. Registered
+// components are started before the web server begins serving and stopped
+// after it has drained
+type Lifecycle interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// Phased may be implemented by a Lifecycle component to control its start order.
+// Components are started in ascending phase order and stopped in the reverse
+// order. Components that don't implement Phased are in phase 0
+type Phased interface {
+	Phase() int
+}
+
+type namedComponent struct {
+	name      string
+	component Lifecycle
+}
+
+func phaseOf(c Lifecycle) int {
+	if p, ok := c.(Phased); ok {
+		return p.Phase()
+	}
+	return 0
+}
+
+// shutdownStep is a single stage of the shutdown sequence
+type shutdownStep func(ctx context.Context) error
+
+// lifecycle coordinates shutting down everything owned by the
+// ApplicationContext exactly once and in a fixed order
+type lifecycle struct {
+	timeout time.Duration
+
+	mu         sync.Mutex
+	hooks      []namedStopHook
+	components []*namedComponent
+	started    []*namedComponent
+
+	watchOnce    sync.Once
+	shutdownOnce sync.Once
+	done         chan struct{}
+	err          error
+}
+
+func newLifecycle(timeout time.Duration) *lifecycle {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	return &lifecycle{
+		timeout: timeout,
+		done:    make(chan struct{}),
+	}
+}
+
+func (l *lifecycle) onStop(name string, hook StopHook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, namedStopHook{name: name, hook: hook})
+}
+
+// stopHooks returns a single step that runs every registered hook in
+// registration order, a failing hook does not prevent the others from running
+func (l *lifecycle) stopHooks() shutdownStep {
+	return func(ctx context.Context) error {
+		l.mu.Lock()
+		hooks := make([]namedStopHook, len(l.hooks))
+		copy(hooks, l.hooks)
+		l.mu.Unlock()
+
+		var err error
+		for _, h := range hooks {
+			if herr := h.hook(ctx); herr != nil {
+				err = multierr.Append(err, fmt.Errorf("stop hook %s failed: %w", h.name, herr))
+			}
+		}
+		return err
+	}
+}
+
+func (l *lifecycle) register(name string, component Lifecycle) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components = append(l.components, &namedComponent{name: name, component: component})
+}
+
+// startComponents starts every registered component that hasn't been started
+// yet in phase order. If a component fails to start, the components started
+// before it are stopped in reverse order and the failure is returned
+func (l *lifecycle) startComponents(ctx context.Context) error {
+	l.mu.Lock()
+	pending := []*namedComponent{}
+	for _, c := range l.components {
+		if !l.isStarted(c) {
+			pending = append(pending, c)
+		}
+	}
+	l.mu.Unlock()
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return phaseOf(pending[i].component) < phaseOf(pending[j].component)
+	})
+	for _, c := range pending {
+		if err := c.component.Start(ctx); err != nil {
+			startErr := fmt.Errorf("failed to start %s: %w", c.name, err)
+			stopCtx, cancel := context.WithTimeout(context.Background(), l.timeout)
+			defer cancel()
+			return multierr.Append(startErr, l.stopComponents()(stopCtx))
+		}
+		l.mu.Lock()
+		l.started = append(l.started, c)
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+// isStarted must be called while holding l.mu
+func (l *lifecycle) isStarted(c *namedComponent) bool {
+	for _, s := range l.started {
+		if s == c {
+			return true
+		}
+	}
+	return false
+}
+
+// stopComponents returns a single step that stops every started component
+// in the reverse order they were started
+func (l *lifecycle) stopComponents() shutdownStep {
+	return func(ctx context.Context) error {
+		l.mu.Lock()
+		started := l.started
+		l.started = nil
+		l.mu.Unlock()
+
+		var err error
+		for i := len(started) - 1; i >= 0; i-- {
+			c := started[i]
+			if serr := c.component.Stop(ctx); serr != nil {
+				err = multierr.Append(err, fmt.Errorf("failed to stop %s: %w", c.name, serr))
+			}
+		}
+		return err
+	}
+}
+
+// shutdown runs the supplied steps in order within the configured timeout. Only
+// the first call performs the shutdown, every caller waits for it to finish
+// and receives the same combined error
+func (l *lifecycle) shutdown(ctx context.Context, steps ...shutdownStep) error {
+	l.shutdownOnce.Do(func() {
+		defer close(l.done)
+		ctx, cancel := context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+		for _, step := range steps {
+			l.err = multierr.Append(l.err, step(ctx))
+		}
+	})
+	<-l.done
+	return l.err
+}
+
+// watch invokes onShutdown when the process receives a termination signal
+// or ctx is canceled. Subsequent calls are no-ops
+func (l *lifecycle) watch(ctx context.Context, onShutdown func()) {
+	l.watchOnce.Do(func() {
+		go func() {
+			signalChan := make(chan os.Signal, 1)
+			signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(signalChan)
+			select {
+			case <-signalChan:
+			case <-ctx.Done():
+			case <-l.done:
+				return
+			}
+			onShutdown()
+		}()
+	})
+}
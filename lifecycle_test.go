@@ -0,0 +1,115 @@
+package go_spec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle_Shutdown(t *testing.T) {
+	var order []string
+	record := func(name string, err error) StopHook {
+		return func(ctx context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+
+	l := newLifecycle(time.Second)
+	l.onStop("first", record("first", nil))
+	l.onStop("second", record("second", errors.New("boom")))
+	l.onStop("third", record("third", nil))
+
+	err := l.shutdown(context.Background(),
+		shutdownStep(record("server", nil)),
+		l.stopHooks(),
+		shutdownStep(record("metrics", nil)),
+	)
+	assert.EqualError(t, err, "stop hook second failed: boom")
+	assert.Equal(t, []string{"server", "first", "second", "third", "metrics"}, order)
+
+	// subsequent calls wait for the first shutdown and report its result
+	err = l.shutdown(context.Background(), shutdownStep(record("again", nil)))
+	assert.EqualError(t, err, "stop hook second failed: boom")
+	assert.Equal(t, []string{"server", "first", "second", "third", "metrics"}, order)
+}
+
+func TestLifecycle_ShutdownTimeout(t *testing.T) {
+	l := newLifecycle(10 * time.Millisecond)
+	err := l.shutdown(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+type testComponent struct {
+	name     string
+	phase    int
+	startErr error
+	events   *[]string
+}
+
+func (tc *testComponent) Start(ctx context.Context) error {
+	*tc.events = append(*tc.events, "start "+tc.name)
+	return tc.startErr
+}
+
+func (tc *testComponent) Stop(ctx context.Context) error {
+	*tc.events = append(*tc.events, "stop "+tc.name)
+	return nil
+}
+
+func (tc *testComponent) Phase() int {
+	return tc.phase
+}
+
+func TestLifecycle_Components(t *testing.T) {
+	cases := map[string]struct {
+		components  []*testComponent
+		expectedErr string
+		expected    []string
+	}{
+		"starts in phase order and stops in reverse": {
+			components: []*testComponent{
+				{name: "consumer", phase: 10},
+				{name: "cache", phase: -10},
+				{name: "poller"},
+			},
+			expected: []string{
+				"start cache", "start poller", "start consumer",
+				"stop consumer", "stop poller", "stop cache",
+			},
+		},
+		"startup failure stops started components": {
+			components: []*testComponent{
+				{name: "cache", phase: 0},
+				{name: "consumer", phase: 1, startErr: errors.New("no brokers")},
+				{name: "poller", phase: 2},
+			},
+			expectedErr: "failed to start consumer: no brokers",
+			expected:    []string{"start cache", "start consumer", "stop cache"},
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			var events []string
+			l := newLifecycle(time.Second)
+			for _, tc := range c.components {
+				tc.events = &events
+				l.register(tc.name, tc)
+			}
+			err := l.startComponents(context.Background())
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, l.shutdown(context.Background(), l.stopComponents()))
+			assert.Equal(t, c.expected, events)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package go_spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/armory-io/go-spec/logging"
+)
+
+var (
+	DefaultLoggersPath = "/armory-observability/loggers"
+)
+
+// LoggerLevels describes the level of a single logger in the
+// loggers endpoint, mirroring Spring Boot's actuator
+type LoggerLevels struct {
+	ConfiguredLevel string `json:"configuredLevel,omitempty"`
+	EffectiveLevel  string `json:"effectiveLevel"`
+}
+
+// LoggersReport is returned when listing every configured logger
+type LoggersReport struct {
+	Levels  []string                `json:"levels"`
+	Loggers map[string]LoggerLevels `json:"loggers"`
+}
+
+// LoggerLevelRequest changes the level of a logger. An empty ConfiguredLevel
+// clears a named logger's level. When RevertAfter is set, e.g. "10m", the
+// previous level is restored once that duration has elapsed
+type LoggerLevelRequest struct {
+	ConfiguredLevel string `json:"configuredLevel"`
+	RevertAfter     string `json:"revertAfter,omitempty"`
+}
+
+// LoggersHandler lists logger levels on GET and changes them on POST. It is
+// meant to be mounted at both path and path + "/", requests to the latter
+// address an individual logger by name, e.g. /loggers/ROOT
+func LoggersHandler(path string, levels *logging.Levels) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, path), "/")
+		switch {
+		case r.Method == http.MethodGet && name == "":
+			writeJSON(w, http.StatusOK, loggersReport(levels))
+		case r.Method == http.MethodGet:
+			writeJSON(w, http.StatusOK, loggerLevels(levels, name))
+		case r.Method == http.MethodPost && name != "":
+			if err := setLoggerLevel(levels, name, r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func loggersReport(levels *logging.Levels) LoggersReport {
+	names := []string{}
+	for lvl := len(logging.AllLevels) - 1; lvl >= 0; lvl-- {
+		names = append(names, logging.AllLevels[lvl].String())
+	}
+	report := LoggersReport{Levels: names, Loggers: map[string]LoggerLevels{}}
+	for _, name := range levels.Names() {
+		report.Loggers[name] = loggerLevels(levels, name)
+	}
+	return report
+}
+
+func loggerLevels(levels *logging.Levels, name string) LoggerLevels {
+	ll := LoggerLevels{EffectiveLevel: levels.Effective(name).String()}
+	if lvl, ok := levels.Configured()[name]; ok {
+		ll.ConfiguredLevel = lvl.String()
+	}
+	return ll
+}
+
+func setLoggerLevel(levels *logging.Levels, name string, r *http.Request) error {
+	var req LoggerLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	if req.ConfiguredLevel == "" {
+		if name == logging.RootLoggerName {
+			return fmt.Errorf("the root level cannot be cleared")
+		}
+		levels.Clear(name)
+		return nil
+	}
+
+	lvl, err := logging.ParseLevel(req.ConfiguredLevel)
+	if err != nil {
+		return err
+	}
+	if req.RevertAfter == "" {
+		levels.Set(name, lvl)
+		return nil
+	}
+	ttl, err := time.ParseDuration(req.RevertAfter)
+	if err != nil || ttl <= 0 {
+		return fmt.Errorf("invalid revertAfter duration: %q", req.RevertAfter)
+	}
+	levels.SetFor(name, lvl, ttl)
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,102 @@
+package go_spec
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/armory-io/go-spec/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggersHandler(t *testing.T) {
+	cases := map[string]struct {
+		method        string
+		path          string
+		body          string
+		expectedCode  int
+		expectedLevel logging.Level
+		name          string
+	}{
+		"changes root level": {
+			method:        http.MethodPost,
+			path:          "/loggers/ROOT",
+			body:          `{"configuredLevel": "DEBUG"}`,
+			expectedCode:  http.StatusNoContent,
+			name:          logging.RootLoggerName,
+			expectedLevel: logging.DebugLevel,
+		},
+		"changes named level with revert": {
+			method:        http.MethodPost,
+			path:          "/loggers/cache",
+			body:          `{"configuredLevel": "trace", "revertAfter": "1h"}`,
+			expectedCode:  http.StatusNoContent,
+			name:          "cache",
+			expectedLevel: logging.TraceLevel,
+		},
+		"clears named level": {
+			method:        http.MethodPost,
+			path:          "/loggers/queue",
+			body:          `{"configuredLevel": ""}`,
+			expectedCode:  http.StatusNoContent,
+			name:          "queue",
+			expectedLevel: logging.InfoLevel,
+		},
+		"rejects unknown level": {
+			method:        http.MethodPost,
+			path:          "/loggers/ROOT",
+			body:          `{"configuredLevel": "LOUD"}`,
+			expectedCode:  http.StatusBadRequest,
+			name:          logging.RootLoggerName,
+			expectedLevel: logging.InfoLevel,
+		},
+		"rejects clearing root": {
+			method:        http.MethodPost,
+			path:          "/loggers/ROOT",
+			body:          `{}`,
+			expectedCode:  http.StatusBadRequest,
+			name:          logging.RootLoggerName,
+			expectedLevel: logging.InfoLevel,
+		},
+		"rejects invalid revert": {
+			method:        http.MethodPost,
+			path:          "/loggers/ROOT",
+			body:          `{"configuredLevel": "DEBUG", "revertAfter": "soon"}`,
+			expectedCode:  http.StatusBadRequest,
+			name:          logging.RootLoggerName,
+			expectedLevel: logging.InfoLevel,
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			levels := logging.NewLevels(logging.InfoLevel)
+			levels.Set("queue", logging.ErrorLevel)
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			LoggersHandler("/loggers", levels).ServeHTTP(rec, req)
+			assert.Equal(t, c.expectedCode, rec.Code)
+			assert.Equal(t, c.expectedLevel, levels.Effective(c.name))
+		})
+	}
+}
+
+func TestLoggersHandler_List(t *testing.T) {
+	levels := logging.NewLevels(logging.WarnLevel)
+	levels.Set("cache", logging.DebugLevel)
+
+	rec := httptest.NewRecorder()
+	LoggersHandler("/loggers", levels).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/loggers", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var report LoggersReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, []string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL", "PANIC"}, report.Levels)
+	assert.Equal(t, map[string]LoggerLevels{
+		"ROOT":  {ConfiguredLevel: "WARN", EffectiveLevel: "WARN"},
+		"cache": {ConfiguredLevel: "DEBUG", EffectiveLevel: "DEBUG"},
+	}, report.Loggers)
+}
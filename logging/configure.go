@@ -18,7 +18,14 @@ func makeAndConfigure(c Config) (*LogrusAdapter, error) {
 	if err := configureLogrus(l, c); err != nil {
 		return nil, err
 	}
-	return &LogrusAdapter{logrus.NewEntry(l)}, nil
+	// the configured level becomes the root level, logrus itself lets
+	// everything through so levels can be changed at runtime
+	levels := NewLevels(Level(l.GetLevel()))
+	if err := configureLevels(levels, c.Levels); err != nil {
+		return nil, err
+	}
+	l.SetLevel(logrus.TraceLevel)
+	return &LogrusAdapter{Entry: logrus.NewEntry(l), levels: levels}, nil
 }
 
 func configureLogrus(l *logrus.Logger, config Config) error {
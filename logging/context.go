@@ -0,0 +1,64 @@
+package logging
+
+import "context"
+
+// Fields attached to log messages by WithContext when they
+// have been stored in the context by go-spec middleware
+const (
+	RequestIDField   = "requestId"
+	TraceIDField     = "traceId"
+	SpanIDField      = "spanId"
+	UserField        = "user"
+	ExecutionIDField = "executionId"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	fieldsKey
+)
+
+// IntoContext returns a copy of ctx that carries logger
+func IntoContext(ctx context.Context, logger LeveledLogger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext returns the logger stored in ctx by IntoContext with any
+// fields stored in ctx attached. A NoopLeveledLogger is returned when
+// ctx doesn't carry a logger
+func FromContext(ctx context.Context) LeveledLogger {
+	logger, ok := ctx.Value(loggerKey).(LeveledLogger)
+	if !ok {
+		return &NoopLeveledLogger{}
+	}
+	return logger.WithContext(ctx)
+}
+
+// ContextWithField returns a copy of ctx that carries an additional
+// field to be attached to log messages by WithContext
+func ContextWithField(ctx context.Context, key string, value interface{}) context.Context {
+	return ContextWithFields(ctx, map[string]interface{}{key: value})
+}
+
+// ContextWithFields returns a copy of ctx that carries additional fields
+// to be attached to log messages by WithContext. Fields already stored in
+// ctx are kept unless they are overwritten
+func ContextWithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	existing := FieldsFromContext(ctx)
+	merged := make(map[string]interface{}, len(existing)+len(fields))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey, merged)
+}
+
+// FieldsFromContext returns the fields stored in ctx. The returned
+// map must not be modified
+func FieldsFromContext(ctx context.Context) map[string]interface{} {
+	fields, _ := ctx.Value(fieldsKey).(map[string]interface{})
+	return fields
+}
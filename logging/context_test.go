@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextWithFields(t *testing.T) {
+	ctx := ContextWithField(context.Background(), RequestIDField, "abc")
+	child := ContextWithFields(ctx, map[string]interface{}{UserField: "anonymous", RequestIDField: "def"})
+
+	assert.Equal(t, map[string]interface{}{RequestIDField: "abc"}, FieldsFromContext(ctx))
+	assert.Equal(t, map[string]interface{}{RequestIDField: "def", UserField: "anonymous"}, FieldsFromContext(child))
+	assert.Nil(t, FieldsFromContext(context.Background()))
+}
+
+func TestFromContext(t *testing.T) {
+	logger, err := makeAndConfigure(Config{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	logger.Logger.SetOutput(&buf)
+	logger.Logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	ctx := IntoContext(context.Background(), logger)
+	ctx = ContextWithFields(ctx, map[string]interface{}{
+		RequestIDField:   "req-1",
+		TraceIDField:     "trace-1",
+		ExecutionIDField: "exec-1",
+	})
+	FromContext(ctx).Info("handling request")
+
+	assert.Contains(t, buf.String(), "requestId=req-1")
+	assert.Contains(t, buf.String(), "traceId=trace-1")
+	assert.Contains(t, buf.String(), "executionId=exec-1")
+	assert.IsType(t, &NoopLeveledLogger{}, FromContext(context.Background()))
+}
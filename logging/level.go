@@ -0,0 +1,291 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/zap/zapcore"
+)
+
+// RootLoggerName is the name used to address the root level
+// when managing levels by name
+const RootLoggerName = "ROOT"
+
+// Level is a logging level, ordered from least to most verbose
+type Level uint32
+
+const (
+	PanicLevel Level = iota
+	FatalLevel
+	ErrorLevel
+	WarnLevel
+	InfoLevel
+	DebugLevel
+	TraceLevel
+)
+
+// AllLevels lists every supported level from least to most verbose
+var AllLevels = []Level{PanicLevel, FatalLevel, ErrorLevel, WarnLevel, InfoLevel, DebugLevel, TraceLevel}
+
+// ParseLevel converts a level name, in any case, into a Level
+func ParseLevel(lvl string) (Level, error) {
+	l, err := logrus.ParseLevel(strings.ToLower(lvl))
+	if err != nil {
+		return InfoLevel, fmt.Errorf("not a valid logging level: %q", lvl)
+	}
+	return Level(l), nil
+}
+
+var levelNames = []string{"PANIC", "FATAL", "ERROR", "WARN", "INFO", "DEBUG", "TRACE"}
+
+func (l Level) String() string {
+	if int(l) < len(levelNames) {
+		return levelNames[l]
+	}
+	return "UNKNOWN"
+}
+
+func (l Level) zapLevel() zapcore.Level {
+	switch l {
+	case PanicLevel:
+		return zapcore.PanicLevel
+	case FatalLevel:
+		return zapcore.FatalLevel
+	case ErrorLevel:
+		return zapcore.ErrorLevel
+	case WarnLevel:
+		return zapcore.WarnLevel
+	case InfoLevel:
+		return zapcore.InfoLevel
+	}
+	return zapcore.DebugLevel
+}
+
+// levelFromZap converts a zap level, zap's development panic level
+// is treated as an error
+func levelFromZap(l zapcore.Level) Level {
+	switch l {
+	case zapcore.PanicLevel:
+		return PanicLevel
+	case zapcore.FatalLevel:
+		return FatalLevel
+	case zapcore.ErrorLevel, zapcore.DPanicLevel:
+		return ErrorLevel
+	case zapcore.WarnLevel:
+		return WarnLevel
+	case zapcore.InfoLevel:
+		return InfoLevel
+	}
+	return DebugLevel
+}
+
+// Levels holds the root level and the levels configured for named loggers.
+// Levels can be changed at runtime and are observed by every logger
+// created from the same Levels
+type Levels struct {
+	mu      sync.RWMutex
+	root    Level
+	named   map[string]Level
+	known   map[string]bool
+	reverts map[string]*pendingRevert
+}
+
+// pendingRevert restores a logger's configuration from before SetFor
+type pendingRevert struct {
+	timer      *time.Timer
+	previous   Level
+	configured bool
+}
+
+// NewLevels creates Levels with the given root level
+func NewLevels(root Level) *Levels {
+	return &Levels{
+		root:    root,
+		named:   map[string]Level{},
+		known:   map[string]bool{},
+		reverts: map[string]*pendingRevert{},
+	}
+}
+
+// Root returns the root level
+func (l *Levels) Root() Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.root
+}
+
+// Configured returns the root level and every explicitly configured
+// named level, keyed by logger name
+func (l *Levels) Configured() map[string]Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	configured := map[string]Level{RootLoggerName: l.root}
+	for name, lvl := range l.named {
+		configured[name] = lvl
+	}
+	return configured
+}
+
+// Names returns the root logger name along with the name of every
+// configured logger and every logger created with Named, sorted
+func (l *Levels) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	seen := map[string]bool{}
+	for name := range l.known {
+		seen[name] = true
+	}
+	for name := range l.named {
+		seen[name] = true
+	}
+	names := []string{RootLoggerName}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// register records that a logger with the given name exists
+func (l *Levels) register(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.known[name] = true
+}
+
+// Effective returns the level that applies to the named logger. Like logback,
+// the level configured for the longest matching dotted prefix of the name
+// wins, e.g. "com.armory.cache.redis" falls back to "com.armory.cache" then
+// "com.armory" and "com" before using the root level
+func (l *Levels) Effective(name string) Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.effective(name)
+}
+
+// effective must be called while holding l.mu
+func (l *Levels) effective(name string) Level {
+	for name != "" {
+		if lvl, ok := l.named[name]; ok {
+			return lvl
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return l.root
+}
+
+// Enabled reports whether a message at lvl should be logged by the named logger
+func (l *Levels) Enabled(name string, lvl Level) bool {
+	return lvl <= l.Effective(name)
+}
+
+// Set changes the level of the named logger, or the root
+// level when name is RootLoggerName
+func (l *Levels) Set(name string, lvl Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cancelRevert(name)
+	l.set(name, lvl)
+}
+
+// Clear removes the level configured for the named logger so that it
+// falls back to the root level. The root level itself cannot be cleared
+func (l *Levels) Clear(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cancelRevert(name)
+	delete(l.named, name)
+}
+
+// SetFor changes the level of the named logger and restores the previous
+// configuration once ttl has elapsed. Calling SetFor again before then
+// extends the change, the configuration from before the first call is still
+// the one restored. Set and Clear cancel the pending revert
+func (l *Levels) SetFor(name string, lvl Level, ttl time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := &pendingRevert{}
+	if pending, ok := l.reverts[name]; ok {
+		pending.timer.Stop()
+		r.previous, r.configured = pending.previous, pending.configured
+	} else if name == RootLoggerName {
+		r.previous, r.configured = l.root, true
+	} else {
+		r.previous, r.configured = l.named[name]
+	}
+	l.set(name, lvl)
+
+	r.timer = time.AfterFunc(ttl, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		// a newer change has taken over this logger
+		if l.reverts[name] != r {
+			return
+		}
+		delete(l.reverts, name)
+		if r.configured {
+			l.set(name, r.previous)
+		} else {
+			delete(l.named, name)
+		}
+	})
+	l.reverts[name] = r
+}
+
+// set must be called while holding l.mu
+func (l *Levels) set(name string, lvl Level) {
+	if name == RootLoggerName || name == "" {
+		l.root = lvl
+		return
+	}
+	l.named[name] = lvl
+}
+
+// cancelRevert must be called while holding l.mu
+func (l *Levels) cancelRevert(name string) {
+	if r, ok := l.reverts[name]; ok {
+		r.timer.Stop()
+		delete(l.reverts, name)
+	}
+}
+
+// configureLevels applies the levels from configuration, keyed by logger
+// name. The "root" key, in any case, sets the root level
+func configureLevels(levels *Levels, configured map[string]string) error {
+	for name, lvl := range configured {
+		parsed, err := ParseLevel(lvl)
+		if err != nil {
+			return fmt.Errorf("invalid level for logger %s: %w", name, err)
+		}
+		if strings.EqualFold(name, RootLoggerName) {
+			name = RootLoggerName
+		}
+		levels.Set(name, parsed)
+	}
+	return nil
+}
+
+// joinName builds the name of a child logger
+func joinName(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	if name == "" {
+		return parent
+	}
+	return parent + "." + name
+}
+
+// LevelsProvider is implemented by loggers whose levels can be managed at runtime
+type LevelsProvider interface {
+	Levels() *Levels
+}
@@ -0,0 +1,224 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseLevel(t *testing.T) {
+	cases := map[string]struct {
+		input       string
+		expected    Level
+		expectedErr bool
+	}{
+		"lower case":    {input: "debug", expected: DebugLevel},
+		"upper case":    {input: "WARN", expected: WarnLevel},
+		"invalid level": {input: "loud", expected: InfoLevel, expectedErr: true},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			lvl, err := ParseLevel(c.input)
+			assert.Equal(t, c.expectedErr, err != nil)
+			assert.Equal(t, c.expected, lvl)
+		})
+	}
+}
+
+func TestLevels_SetFor(t *testing.T) {
+	levels := NewLevels(InfoLevel)
+	levels.Set("cache", WarnLevel)
+
+	levels.SetFor(RootLoggerName, DebugLevel, 20*time.Millisecond)
+	levels.SetFor("cache", TraceLevel, 20*time.Millisecond)
+	levels.SetFor("queue", ErrorLevel, 20*time.Millisecond)
+	assert.Equal(t, DebugLevel, levels.Root())
+	assert.Equal(t, TraceLevel, levels.Effective("cache"))
+	assert.Equal(t, ErrorLevel, levels.Effective("queue"))
+
+	assert.Eventually(t, func() bool {
+		return levels.Root() == InfoLevel && levels.Effective("cache") == WarnLevel
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, map[string]Level{RootLoggerName: InfoLevel, "cache": WarnLevel}, levels.Configured())
+}
+
+func TestLevels_SetForOverlapping(t *testing.T) {
+	levels := NewLevels(InfoLevel)
+	levels.Set("cache", WarnLevel)
+
+	levels.SetFor("cache", DebugLevel, time.Hour)
+	levels.SetFor("cache", TraceLevel, 20*time.Millisecond)
+	levels.SetFor("queue", DebugLevel, time.Hour)
+	levels.SetFor("queue", TraceLevel, 20*time.Millisecond)
+	assert.Equal(t, TraceLevel, levels.Effective("cache"))
+
+	assert.Eventually(t, func() bool {
+		return levels.Effective("cache") == WarnLevel && levels.Effective("queue") == InfoLevel
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, map[string]Level{RootLoggerName: InfoLevel, "cache": WarnLevel}, levels.Configured())
+}
+
+func TestLevels_SetCancelsRevert(t *testing.T) {
+	levels := NewLevels(InfoLevel)
+	levels.SetFor(RootLoggerName, DebugLevel, 10*time.Millisecond)
+	levels.Set(RootLoggerName, ErrorLevel)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, ErrorLevel, levels.Root())
+}
+
+func TestLogrusAdapter_RuntimeLevels(t *testing.T) {
+	logger, err := makeAndConfigure(Config{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	logger.Logger.SetOutput(&buf)
+	logger.Logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	logger.Debug("hidden")
+	logger.Levels().Set(RootLoggerName, DebugLevel)
+	logger.WithField("k", "v").Debug("visible")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "visible")
+}
+
+func TestLogrusAdapter_PromotedMethodsObserveLevels(t *testing.T) {
+	logger, err := makeAndConfigure(Config{Levels: map[string]string{"root": "warn"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	logger.Logger.SetOutput(&buf)
+	logger.Logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	logger.Trace("trace")
+	logger.Tracef("tracef")
+	logger.Print("print")
+	logger.Printf("printf")
+	logger.Infoln("infoln")
+	logger.Log(logrus.DebugLevel, "log")
+	logger.WithError(assert.AnError).Debug("with error")
+	logger.WithTime(time.Now()).Info("with time")
+	logger.WithFields(map[string]interface{}{"k": "v"}).Debug("with fields")
+	logger.Warnln("warnln")
+
+	assert.Equal(t, "level=warning msg=warnln\n", buf.String())
+}
+
+func newObservedZapAdapter(levels *Levels) (*ZapAdapter, *observer.ObservedLogs) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	return newZapAdapter(zap.New(core), levels), logs
+}
+
+func observedMessages(logs *observer.ObservedLogs) []string {
+	var messages []string
+	for _, e := range logs.All() {
+		messages = append(messages, e.Message)
+	}
+	return messages
+}
+
+func TestZapAdapter_RuntimeLevels(t *testing.T) {
+	logger, logs := newObservedZapAdapter(NewLevels(InfoLevel))
+
+	logger.Debug("hidden")
+	logger.Levels().Set(RootLoggerName, DebugLevel)
+	logger.WithField("k", "v").Debug("visible")
+
+	assert.Equal(t, []string{"visible"}, observedMessages(logs))
+}
+
+func TestZapAdapter_PromotedMethodsObserveLevels(t *testing.T) {
+	logger, logs := newObservedZapAdapter(NewLevels(WarnLevel))
+
+	logger.Debugw("debugw")
+	logger.Infow("infow", "k", "v")
+	logger.With("k", "v").Info("with")
+	logger.Desugar().Info("desugared")
+	logger.WithFields(map[string]interface{}{"k": "v"}).Debug("with fields")
+	logger.Warnw("warnw")
+	logger.Errorw("errorw")
+
+	assert.Equal(t, []string{"warnw", "errorw"}, observedMessages(logs))
+}
+
+func TestLevels_Effective(t *testing.T) {
+	levels := NewLevels(InfoLevel)
+	if err := configureLevels(levels, map[string]string{
+		"root":                   "warn",
+		"com.armory":             "error",
+		"com.armory.cache":       "debug",
+		"com.armory.cache.redis": "trace",
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := map[string]struct {
+		name     string
+		expected Level
+	}{
+		"exact match":             {name: "com.armory.cache.redis", expected: TraceLevel},
+		"longest prefix wins":     {name: "com.armory.cache.memcached", expected: DebugLevel},
+		"shorter prefix":          {name: "com.armory.queue", expected: ErrorLevel},
+		"partial segment ignored": {name: "com.armorycache", expected: WarnLevel},
+		"falls back to root":      {name: "org.other", expected: WarnLevel},
+		"root by name":            {name: RootLoggerName, expected: WarnLevel},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, c.expected, levels.Effective(c.name))
+		})
+	}
+}
+
+func TestLogrusAdapter_Named(t *testing.T) {
+	logger, err := makeAndConfigure(Config{Levels: map[string]string{"cache": "debug"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var buf bytes.Buffer
+	logger.Logger.SetOutput(&buf)
+	logger.Logger.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	logger.Debug("root debug")
+	cache := logger.Named("cache")
+	cache.Named("redis").Debug("redis debug")
+	logger.Named("queue").Debug("queue debug")
+
+	assert.NotContains(t, buf.String(), "root debug")
+	assert.NotContains(t, buf.String(), "queue debug")
+	assert.Contains(t, buf.String(), "logger=cache.redis")
+	assert.Equal(t, []string{RootLoggerName, "cache", "cache.redis", "queue"}, logger.Levels().Names())
+}
+
+func TestZapAdapter_Named(t *testing.T) {
+	levels := NewLevels(InfoLevel)
+	levels.Set("cache", DebugLevel)
+	logger, logs := newObservedZapAdapter(levels)
+
+	logger.Debug("root debug")
+	cache := logger.Named("cache")
+	cache.Named("redis").Debug("redis debug")
+	cache.WithField("k", "v").Debug("cache debug")
+	logger.Named("queue").Debug("queue debug")
+
+	assert.Equal(t, []string{"redis debug", "cache debug"}, observedMessages(logs))
+	assert.Equal(t, "cache.redis", logs.All()[0].LoggerName)
+	assert.Equal(t, []string{RootLoggerName, "cache", "cache.redis", "queue"}, levels.Names())
+
+	levels.Set("cache.redis", WarnLevel)
+	cache.Named("redis").Info("redis info")
+	assert.Len(t, logs.All(), 2)
+}
+
+func TestConfigureLevels_InvalidLevel(t *testing.T) {
+	_, err := makeAndConfigure(Config{Levels: map[string]string{"cache": "loud"}})
+	assert.EqualError(t, err, `invalid level for logger cache: not a valid logging level: "loud"`)
+}
@@ -1,17 +1,185 @@
 package logging
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"time"
 
+	"github.com/sirupsen/logrus"
+)
+
+// LogrusAdapter implements LeveledLogger using logrus. When created with
+// Levels, the adapter decides which messages are logged so that levels
+// can be changed at runtime
 type LogrusAdapter struct {
 	*logrus.Entry
+	levels *Levels
+	name   string
 }
 
 func (l *LogrusAdapter) WithField(key string, value interface{}) LeveledLogger {
-	withField := l.Logger.WithField(key, value)
-	return &LogrusAdapter{withField}
+	withField := l.Entry.WithField(key, value)
+	return &LogrusAdapter{Entry: withField, levels: l.levels, name: l.name}
 }
 
 func (l *LogrusAdapter) WithFields(fields map[string]interface{}) LeveledLogger {
-	withFields := l.Logger.WithFields(fields)
-	return &LogrusAdapter{withFields}
+	withFields := l.Entry.WithFields(fields)
+	return &LogrusAdapter{Entry: withFields, levels: l.levels, name: l.name}
+}
+
+// LoggerNameField is the field used to record the name of a named logger
+const LoggerNameField = "logger"
+
+func (l *LogrusAdapter) Named(name string) LeveledLogger {
+	full := joinName(l.name, name)
+	if l.levels != nil {
+		l.levels.register(full)
+	}
+	return &LogrusAdapter{Entry: l.Entry.WithField(LoggerNameField, full), levels: l.levels, name: full}
+}
+
+func (l *LogrusAdapter) WithContext(ctx context.Context) LeveledLogger {
+	fields := FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return l
+	}
+	return l.WithFields(fields)
+}
+
+// Levels returns the levels observed by this logger
+func (l *LogrusAdapter) Levels() *Levels {
+	return l.levels
+}
+
+func (l *LogrusAdapter) enabled(lvl Level) bool {
+	return l.levels == nil || l.levels.Enabled(l.name, lvl)
+}
+
+// Log logs at the given level. Every logging method of the adapter goes
+// through Log, Logf or Logln so that the methods promoted from the embedded
+// entry also observe the adapter's levels, logrus itself logs every level
+func (l *LogrusAdapter) Log(level logrus.Level, args ...interface{}) {
+	if l.enabled(Level(level)) {
+		l.Entry.Log(level, args...)
+	}
+}
+
+func (l *LogrusAdapter) Logf(level logrus.Level, format string, args ...interface{}) {
+	if l.enabled(Level(level)) {
+		l.Entry.Logf(level, format, args...)
+	}
+}
+
+func (l *LogrusAdapter) Logln(level logrus.Level, args ...interface{}) {
+	if l.enabled(Level(level)) {
+		l.Entry.Logln(level, args...)
+	}
+}
+
+// WithError and WithTime return adapters, rather than entries,
+// so that the levels still apply to what is logged through them
+func (l *LogrusAdapter) WithError(err error) *LogrusAdapter {
+	return &LogrusAdapter{Entry: l.Entry.WithError(err), levels: l.levels, name: l.name}
+}
+
+func (l *LogrusAdapter) WithTime(t time.Time) *LogrusAdapter {
+	return &LogrusAdapter{Entry: l.Entry.WithTime(t), levels: l.levels, name: l.name}
+}
+
+func (l *LogrusAdapter) Trace(args ...interface{}) {
+	l.Log(logrus.TraceLevel, args...)
+}
+
+func (l *LogrusAdapter) Debug(args ...interface{}) {
+	l.Log(logrus.DebugLevel, args...)
+}
+
+func (l *LogrusAdapter) Print(args ...interface{}) {
+	l.Log(logrus.InfoLevel, args...)
+}
+
+func (l *LogrusAdapter) Info(args ...interface{}) {
+	l.Log(logrus.InfoLevel, args...)
+}
+
+func (l *LogrusAdapter) Warn(args ...interface{}) {
+	l.Log(logrus.WarnLevel, args...)
+}
+
+func (l *LogrusAdapter) Warning(args ...interface{}) {
+	l.Log(logrus.WarnLevel, args...)
+}
+
+func (l *LogrusAdapter) Error(args ...interface{}) {
+	l.Log(logrus.ErrorLevel, args...)
+}
+
+func (l *LogrusAdapter) Fatal(args ...interface{}) {
+	l.Log(logrus.FatalLevel, args...)
+	l.Logger.Exit(1)
+}
+
+func (l *LogrusAdapter) Tracef(format string, args ...interface{}) {
+	l.Logf(logrus.TraceLevel, format, args...)
+}
+
+func (l *LogrusAdapter) Debugf(format string, args ...interface{}) {
+	l.Logf(logrus.DebugLevel, format, args...)
+}
+
+func (l *LogrusAdapter) Printf(format string, args ...interface{}) {
+	l.Logf(logrus.InfoLevel, format, args...)
+}
+
+func (l *LogrusAdapter) Infof(format string, args ...interface{}) {
+	l.Logf(logrus.InfoLevel, format, args...)
+}
+
+func (l *LogrusAdapter) Warnf(format string, args ...interface{}) {
+	l.Logf(logrus.WarnLevel, format, args...)
+}
+
+func (l *LogrusAdapter) Warningf(format string, args ...interface{}) {
+	l.Logf(logrus.WarnLevel, format, args...)
+}
+
+func (l *LogrusAdapter) Errorf(format string, args ...interface{}) {
+	l.Logf(logrus.ErrorLevel, format, args...)
+}
+
+func (l *LogrusAdapter) Fatalf(format string, args ...interface{}) {
+	l.Logf(logrus.FatalLevel, format, args...)
+	l.Logger.Exit(1)
+}
+
+func (l *LogrusAdapter) Traceln(args ...interface{}) {
+	l.Logln(logrus.TraceLevel, args...)
+}
+
+func (l *LogrusAdapter) Debugln(args ...interface{}) {
+	l.Logln(logrus.DebugLevel, args...)
+}
+
+func (l *LogrusAdapter) Println(args ...interface{}) {
+	l.Logln(logrus.InfoLevel, args...)
+}
+
+func (l *LogrusAdapter) Infoln(args ...interface{}) {
+	l.Logln(logrus.InfoLevel, args...)
+}
+
+func (l *LogrusAdapter) Warnln(args ...interface{}) {
+	l.Logln(logrus.WarnLevel, args...)
+}
+
+func (l *LogrusAdapter) Warningln(args ...interface{}) {
+	l.Logln(logrus.WarnLevel, args...)
+}
+
+func (l *LogrusAdapter) Errorln(args ...interface{}) {
+	l.Logln(logrus.ErrorLevel, args...)
+}
+
+func (l *LogrusAdapter) Fatalln(args ...interface{}) {
+	l.Logln(logrus.FatalLevel, args...)
+	l.Logger.Exit(1)
 }
@@ -1,5 +1,7 @@
 package logging
 
+import "context"
+
 // NoopLeveledLogger implements the LeveledLogger interface
 // and throws away all output
 type NoopLeveledLogger struct{}
@@ -12,6 +14,14 @@ func (n *NoopLeveledLogger) WithFields(fields map[string]interface{}) LeveledLog
 	return n
 }
 
+func (n *NoopLeveledLogger) Named(name string) LeveledLogger {
+	return n
+}
+
+func (n *NoopLeveledLogger) WithContext(ctx context.Context) LeveledLogger {
+	return n
+}
+
 func (n *NoopLeveledLogger) Debugf(format string, args ...interface{}) {
 }
 
@@ -1,10 +1,18 @@
 package logging
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
 
 type Config struct {
 	Remote RemoteLoggingConfig `json:"remote" yaml:"remote"`
 	JSON   FormatJson          `json:"json" yaml:"json"`
+	// Levels configures the level of named loggers, e.g. `com.armory.cache: debug`.
+	// Loggers without a level of their own use the level of their longest
+	// configured dotted prefix. The `root` key sets the root level
+	Levels map[string]string `json:"levels" yaml:"levels"`
 }
 
 type RemoteLoggingConfig struct {
@@ -51,6 +59,12 @@ func (fj *FormatJson) Configure(l *logrus.Logger) {
 type LeveledLogger interface {
 	WithField(key string, value interface{}) LeveledLogger
 	WithFields(fields map[string]interface{}) LeveledLogger
+	// Named returns a child logger whose level can be configured independently.
+	// Names are hierarchical, calling Named on a named logger joins the names with "."
+	Named(name string) LeveledLogger
+	// WithContext returns a logger with the fields stored in ctx attached,
+	// such as the request ID, trace and span IDs and Spinnaker user
+	WithContext(ctx context.Context) LeveledLogger
 
 	Debugf(format string, args ...interface{})
 	Infof(format string, args ...interface{})
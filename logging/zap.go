@@ -1,14 +1,24 @@
 package logging
 
-import "go.uber.org/zap"
+import (
+	"context"
 
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZapAdapter implements LeveledLogger using zap. When created with
+// Levels, zap consults them before logging each message so that levels
+// can be changed at runtime
 type ZapAdapter struct {
 	*zap.SugaredLogger
+	levels *Levels
+	name   string
 }
 
 func (z *ZapAdapter) WithField(key string, value interface{}) LeveledLogger {
 	fz := z.SugaredLogger.With(key, value)
-	return &ZapAdapter{fz}
+	return &ZapAdapter{SugaredLogger: fz, levels: z.levels, name: z.name}
 }
 
 func (z *ZapAdapter) WithFields(fields map[string]interface{}) LeveledLogger {
@@ -16,11 +26,75 @@ func (z *ZapAdapter) WithFields(fields map[string]interface{}) LeveledLogger {
 	for k, v := range fields {
 		fz = fz.With(k, v)
 	}
-	return &ZapAdapter{fz}
+	return &ZapAdapter{SugaredLogger: fz, levels: z.levels, name: z.name}
+}
+
+func (z *ZapAdapter) Named(name string) LeveledLogger {
+	full := joinName(z.name, name)
+	if z.levels == nil {
+		return &ZapAdapter{SugaredLogger: z.SugaredLogger.Named(name), name: full}
+	}
+	z.levels.register(full)
+	named := z.SugaredLogger.Named(name).Desugar().WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		if lc, ok := c.(*levelCore); ok {
+			return &levelCore{Core: lc.Core, levels: lc.levels, name: full}
+		}
+		return c
+	}))
+	return &ZapAdapter{SugaredLogger: named.Sugar(), levels: z.levels, name: full}
+}
+
+func (z *ZapAdapter) WithContext(ctx context.Context) LeveledLogger {
+	fields := FieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return z
+	}
+	return z.WithFields(fields)
+}
+
+// Levels returns the levels observed by this logger
+func (z *ZapAdapter) Levels() *Levels {
+	return z.levels
+}
+
+// levelCore lets through the entries enabled by Levels for the named logger
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+	name   string
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.levels.Enabled(c.name, levelFromZap(lvl))
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels, name: c.name}
+}
+
+func (c *levelCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(e.Level) {
+		return ce
+	}
+	return c.Core.Check(e, ce)
+}
+
+// newZapAdapter creates a ZapAdapter whose messages are filtered by levels
+func newZapAdapter(l *zap.Logger, levels *Levels) *ZapAdapter {
+	l = l.WithOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return &levelCore{Core: c, levels: levels}
+	}))
+	return &ZapAdapter{SugaredLogger: l.Sugar(), levels: levels}
 }
 
 func NewZapLeveledLogger() (LeveledLogger, error) {
-	l, _ := zap.NewProduction()
-	sl := l.Sugar()
-	return &ZapAdapter{sl}, nil
+	// zap itself lets everything through, the adapter's core filters
+	// messages using Levels so they can be changed at runtime
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(DebugLevel.zapLevel())
+	l, err := cfg.Build()
+	if err != nil {
+		return nil, err
+	}
+	return newZapAdapter(l, NewLevels(InfoLevel)), nil
 }
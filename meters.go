@@ -0,0 +1,516 @@
+package go_spec
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+)
+
+type meterType int
+
+const (
+	unknownMeter meterType = iota
+	counterMeter
+	gaugeMeter
+	timerMeter
+	summaryMeter
+	longTaskTimerMeter
+	gaugeFuncMeter
+)
+
+func (t meterType) String() string {
+	switch t {
+	case counterMeter:
+		return "counter"
+	case gaugeMeter:
+		return "gauge"
+	case timerMeter:
+		return "timer"
+	case summaryMeter:
+		return "distribution summary"
+	case longTaskTimerMeter:
+		return "long task timer"
+	case gaugeFuncMeter:
+		return "function gauge"
+	}
+	return "unknown"
+}
+
+// MeterOpts describes a meter registered with the MetricsServer
+type MeterOpts struct {
+	// Description is published as the HELP text of the Prometheus metric
+	Description string
+	// BaseUnit, e.g. "bytes", is appended to the name of the Prometheus
+	// metric. Timers are always recorded in milliseconds
+	BaseUnit string
+	// Labels are attached to every value recorded by the meter, in
+	// addition to the metrics server's default labels
+	Labels map[string]string
+	// Histogram publishes timers and distribution summaries as Prometheus
+	// histograms. Its Prefix is ignored. When nil, the histograms declared
+	// by the MetricsServerConfig apply
+	Histogram *HistogramConfig
+}
+
+type meterDescription struct {
+	kind      meterType
+	help      string
+	unit      string
+	histogram *HistogramConfig
+}
+
+// meterRegistry holds the meters created through the typed API so that
+// they are reused and their descriptions can be published
+type meterRegistry struct {
+	mu           sync.RWMutex
+	descriptions map[string]meterDescription
+	meters       map[string]interface{}
+}
+
+func newMeterRegistry() *meterRegistry {
+	return &meterRegistry{
+		descriptions: map[string]meterDescription{},
+		meters:       map[string]interface{}{},
+	}
+}
+
+func (mr *meterRegistry) description(name string) (meterDescription, bool) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	d, ok := mr.descriptions[name]
+	return d, ok
+}
+
+// register returns the meter already registered under name and labels, or
+// the one created by create. Every meter sharing a name must be of the same
+// type, the first description registered for a name is kept
+func (mr *meterRegistry) register(name string, d meterDescription, labels []metrics.Label, create func() interface{}) (interface{}, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if err := mr.describe(name, d); err != nil {
+		return nil, err
+	}
+	hash := meterHash(name, labels)
+	if m, ok := mr.meters[hash]; ok {
+		return m, nil
+	}
+	m := create()
+	mr.meters[hash] = m
+	return m, nil
+}
+
+// registerGaugeFunc adds g to the registry. Unlike other meters, function
+// gauges aren't shared since each one reports its own function
+func (mr *meterRegistry) registerGaugeFunc(g *GaugeFunc, d meterDescription) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if err := mr.describe(g.name, d); err != nil {
+		return err
+	}
+	if _, ok := mr.meters[g.hash]; ok {
+		return fmt.Errorf("gauge %s is already registered with these labels", g.name)
+	}
+	mr.meters[g.hash] = g
+	return nil
+}
+
+// describe records the description of name, the caller must hold mu
+func (mr *meterRegistry) describe(name string, d meterDescription) error {
+	if existing, ok := mr.descriptions[name]; ok && existing.kind != d.kind {
+		return fmt.Errorf("metric %s is already registered as a %s", name, existing.kind)
+	} else if !ok {
+		mr.descriptions[name] = d
+	}
+	return nil
+}
+
+// remove removes m unless another meter has since replaced it
+func (mr *meterRegistry) remove(hash string, m interface{}) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if mr.meters[hash] == m {
+		delete(mr.meters, hash)
+	}
+}
+
+// gaugeFuncs returns the function gauges currently registered
+func (mr *meterRegistry) gaugeFuncs() []*GaugeFunc {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	var gauges []*GaugeFunc
+	for _, m := range mr.meters {
+		if g, ok := m.(*GaugeFunc); ok {
+			gauges = append(gauges, g)
+		}
+	}
+	return gauges
+}
+
+func meterHash(name string, labels []metrics.Label) string {
+	hash := name
+	for _, l := range labels {
+		hash += fmt.Sprintf(";%s=%s", l.Name, l.Value)
+	}
+	return hash
+}
+
+// meterLabels returns the labels of a meter, sorted by name,
+// followed by the metrics server's default labels
+func (ms *MetricsServer) meterLabels(opts MeterOpts) []metrics.Label {
+	names := make([]string, 0, len(opts.Labels))
+	for name := range opts.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	labels := make([]metrics.Label, 0, len(names)+len(ms.defaultLabels))
+	for _, name := range names {
+		labels = append(labels, metrics.Label{Name: name, Value: opts.Labels[name]})
+	}
+	return append(labels, ms.defaultLabels...)
+}
+
+func (ms *MetricsServer) registerMeter(name string, kind meterType, opts MeterOpts, create func(key []string, labels []metrics.Label) interface{}) (interface{}, error) {
+	d := meterDescription{kind: kind, help: opts.Description, unit: opts.BaseUnit, histogram: opts.Histogram}
+	if kind == timerMeter {
+		d.unit = "milliseconds"
+	}
+	labels := ms.meterLabels(opts)
+	return ms.meters.register(name, d, labels, func() interface{} {
+		return create([]string{name}, labels)
+	})
+}
+
+// Counter counts occurrences of an event
+type Counter struct {
+	metrics *metrics.Metrics
+	key     []string
+	labels  []metrics.Label
+}
+
+// Counter returns the counter registered under name with opts' labels,
+// creating it if needed
+func (ms *MetricsServer) Counter(name string, opts MeterOpts) (*Counter, error) {
+	m, err := ms.registerMeter(name, counterMeter, opts, func(key []string, labels []metrics.Label) interface{} {
+		return &Counter{metrics: ms.metrics, key: key, labels: labels}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(*Counter), nil
+}
+
+// Increment adds one to the counter
+func (c *Counter) Increment() {
+	c.Add(1)
+}
+
+// Add adds v to the counter
+func (c *Counter) Add(v float64) {
+	c.metrics.IncrCounterWithLabels(c.key, float32(v), c.labels)
+}
+
+// Gauge reports the last value it was set to
+type Gauge struct {
+	metrics *metrics.Metrics
+	key     []string
+	labels  []metrics.Label
+}
+
+// Gauge returns the gauge registered under name with opts' labels,
+// creating it if needed
+func (ms *MetricsServer) Gauge(name string, opts MeterOpts) (*Gauge, error) {
+	m, err := ms.registerMeter(name, gaugeMeter, opts, func(key []string, labels []metrics.Label) interface{} {
+		return &Gauge{metrics: ms.metrics, key: key, labels: labels}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(*Gauge), nil
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.metrics.SetGaugeWithLabels(g.key, float32(v), g.labels)
+}
+
+// GaugeFunc reports the value returned by a function, such as the depth of a
+// queue or the size of a pool, evaluated each time Prometheus scrapes metrics.
+// For the other sinks, the function is sampled every GaugeFuncInterval
+type GaugeFunc struct {
+	meters *meterRegistry
+	name   string
+	hash   string
+	labels []metrics.Label
+	fn     func() float64
+}
+
+// GaugeFunc registers a gauge reporting fn under name with opts' labels.
+// Registering the same name and labels again fails until the gauge is removed
+func (ms *MetricsServer) GaugeFunc(name string, opts MeterOpts, fn func() float64) (*GaugeFunc, error) {
+	labels := ms.meterLabels(opts)
+	g := &GaugeFunc{
+		meters: ms.meters,
+		name:   name,
+		hash:   meterHash(name, labels),
+		labels: labels,
+		fn:     fn,
+	}
+	d := meterDescription{kind: gaugeFuncMeter, help: opts.Description, unit: opts.BaseUnit}
+	if err := ms.meters.registerGaugeFunc(g, d); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Value evaluates the gauge's function
+func (g *GaugeFunc) Value() float64 {
+	return g.fn()
+}
+
+// sample evaluates the gauge's function, turning a panic into an error
+func (g *GaugeFunc) sample() (v float64, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("gauge %s panicked: %v", g.name, r)
+		}
+	}()
+	return g.Value(), nil
+}
+
+// pushGaugeFuncs samples the function gauges every interval and records
+// them as gauges, for the sinks that aren't scraped, until the server's
+// context is done or stop is called. stop waits for a sample in progress
+func (ms *MetricsServer) pushGaugeFuncs(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ms.sampleGaugeFuncs()
+			case <-ms.ctx.Done():
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
+
+// sampleGaugeFuncs records the current value of every function gauge,
+// gauges whose function panics are skipped
+func (ms *MetricsServer) sampleGaugeFuncs() {
+	for _, g := range ms.meters.gaugeFuncs() {
+		if v, err := g.sample(); err == nil {
+			ms.metrics.SetGaugeWithLabels([]string{g.name}, float32(v), g.labels)
+		}
+	}
+}
+
+// Remove stops reporting the gauge, e.g. when the component it
+// observes is stopped
+func (g *GaugeFunc) Remove() {
+	g.meters.remove(g.hash, g)
+}
+
+// Timer records the duration of operations, in milliseconds
+type Timer struct {
+	metrics *metrics.Metrics
+	key     []string
+	labels  []metrics.Label
+}
+
+// Timer returns the timer registered under name with opts' labels,
+// creating it if needed
+func (ms *MetricsServer) Timer(name string, opts MeterOpts) (*Timer, error) {
+	m, err := ms.registerMeter(name, timerMeter, opts, func(key []string, labels []metrics.Label) interface{} {
+		return &Timer{metrics: ms.metrics, key: key, labels: labels}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(*Timer), nil
+}
+
+// Record records an operation that took d
+func (t *Timer) Record(d time.Duration) {
+	t.metrics.AddSampleWithLabels(t.key, float32(d)/float32(time.Millisecond), t.labels)
+}
+
+// Since records an operation that started at start
+func (t *Timer) Since(start time.Time) {
+	t.Record(time.Since(start))
+}
+
+// Time calls fn and records how long it took
+func (t *Timer) Time(fn func()) {
+	defer t.Since(time.Now())
+	fn()
+}
+
+// DistributionSummary records the distribution of values, such as payload sizes
+type DistributionSummary struct {
+	metrics *metrics.Metrics
+	key     []string
+	labels  []metrics.Label
+}
+
+// DistributionSummary returns the distribution summary registered under name
+// with opts' labels, creating it if needed
+func (ms *MetricsServer) DistributionSummary(name string, opts MeterOpts) (*DistributionSummary, error) {
+	m, err := ms.registerMeter(name, summaryMeter, opts, func(key []string, labels []metrics.Label) interface{} {
+		return &DistributionSummary{metrics: ms.metrics, key: key, labels: labels}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.(*DistributionSummary), nil
+}
+
+// Record records v
+func (s *DistributionSummary) Record(v float64) {
+	s.metrics.AddSampleWithLabels(s.key, float32(v), s.labels)
+}
+
+// LongTaskTimer tracks operations while they are running, such as pipeline
+// executions or bakes, rather than once they complete, so that operations
+// stuck for too long can be alerted on. The number of running tasks, and
+// their total and longest durations in milliseconds, are reported by the
+// function gauges <name>.active.count, <name>.duration.sum and
+// <name>.duration.max. Like every function gauge, they are evaluated when
+// Prometheus scrapes them and sampled every GaugeFuncInterval for the
+// other sinks
+type LongTaskTimer struct {
+	init    sync.Once
+	initErr error
+
+	mu    sync.Mutex
+	tasks map[*LongTaskTimerSample]bool
+}
+
+// LongTaskTimerSample is a single running task
+type LongTaskTimerSample struct {
+	timer *LongTaskTimer
+	start time.Time
+}
+
+// LongTaskTimer returns the long task timer registered under name with
+// opts' labels, creating it if needed
+func (ms *MetricsServer) LongTaskTimer(name string, opts MeterOpts) (*LongTaskTimer, error) {
+	m, err := ms.registerMeter(name, longTaskTimerMeter, opts, func(key []string, labels []metrics.Label) interface{} {
+		return &LongTaskTimer{tasks: map[*LongTaskTimerSample]bool{}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	t := m.(*LongTaskTimer)
+	// the gauges are registered once the timer is, as meters can't
+	// be registered while the registry is creating one
+	t.init.Do(func() {
+		gauges := MeterOpts{Description: opts.Description, Labels: opts.Labels}
+		if _, err := ms.GaugeFunc(name+".active.count", gauges, func() float64 {
+			return float64(t.ActiveTasks())
+		}); err != nil {
+			t.initErr = err
+			return
+		}
+		if _, err := ms.GaugeFunc(name+".duration.sum", gauges, func() float64 {
+			return float64(t.Duration()) / float64(time.Millisecond)
+		}); err != nil {
+			t.initErr = err
+			return
+		}
+		_, t.initErr = ms.GaugeFunc(name+".duration.max", gauges, func() float64 {
+			return float64(t.Max()) / float64(time.Millisecond)
+		})
+	})
+	if t.initErr != nil {
+		return nil, t.initErr
+	}
+	return t, nil
+}
+
+// Start starts tracking a task, which runs until Stop is called on the sample
+func (t *LongTaskTimer) Start() *LongTaskTimerSample {
+	s := &LongTaskTimerSample{timer: t, start: time.Now()}
+	t.mu.Lock()
+	t.tasks[s] = true
+	t.mu.Unlock()
+	return s
+}
+
+// Record tracks fn as a task while it runs
+func (t *LongTaskTimer) Record(fn func()) {
+	defer t.Start().Stop()
+	fn()
+}
+
+// StartContext tracks a task until the returned context is done, either
+// because cancel is called or ctx is done
+func (t *LongTaskTimer) StartContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	s := t.Start()
+	go func() {
+		<-ctx.Done()
+		s.Stop()
+	}()
+	return ctx, cancel
+}
+
+// ActiveTasks returns the number of running tasks
+func (t *LongTaskTimer) ActiveTasks() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tasks)
+}
+
+// Duration returns the sum of the running tasks' durations
+func (t *LongTaskTimer) Duration() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total time.Duration
+	for s := range t.tasks {
+		total += s.Duration()
+	}
+	return total
+}
+
+// Max returns the duration of the longest running task
+func (t *LongTaskTimer) Max() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var max time.Duration
+	for s := range t.tasks {
+		if d := s.Duration(); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// Duration returns how long the task has been running for
+func (s *LongTaskTimerSample) Duration() time.Duration {
+	return time.Since(s.start)
+}
+
+// Stop stops tracking the task and returns how long it ran for.
+// Calling Stop more than once has no effect
+func (s *LongTaskTimerSample) Stop() time.Duration {
+	t := s.timer
+	t.mu.Lock()
+	delete(t.tasks, s)
+	t.mu.Unlock()
+	return s.Duration()
+}
@@ -0,0 +1,299 @@
+package go_spec
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsServer_MeterRegistration(t *testing.T) {
+	ms, sink := newTestMetricsServer(t)
+
+	first, err := ms.Counter("pipelines.started", MeterOpts{Labels: map[string]string{"app": "deck"}})
+	assert.NoError(t, err)
+	second, err := ms.Counter("pipelines.started", MeterOpts{Labels: map[string]string{"app": "deck"}})
+	assert.NoError(t, err)
+	other, err := ms.Counter("pipelines.started", MeterOpts{Labels: map[string]string{"app": "gate"}})
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.NotSame(t, first, other)
+
+	first.Increment()
+	second.Increment()
+	other.Increment()
+	assert.Equal(t, 3, counterTotal(sink, "pipelines.started"))
+
+	_, err = ms.Timer("pipelines.started", MeterOpts{})
+	assert.EqualError(t, err, "metric pipelines.started is already registered as a counter")
+}
+
+func TestMetricsServer_MetersPrometheus(t *testing.T) {
+	reg := prom.NewRegistry()
+	ms, err := NewDefaultMetricsServer(MetricsServerConfig{ServiceName: "app", Registry: reg})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	counter, _ := ms.Counter("pipelines.started", MeterOpts{Description: "Pipelines started"})
+	counter.Increment()
+	gauge, _ := ms.Gauge("queue.size", MeterOpts{Description: "Tasks waiting to run", BaseUnit: "tasks"})
+	gauge.Set(3)
+	timer, _ := ms.Timer("stage.duration", MeterOpts{Description: "Time spent running stages"})
+	timer.Record(50 * time.Millisecond)
+	histogram, _ := ms.Timer("task.duration", MeterOpts{Histogram: &HistogramConfig{Buckets: []float64{100}}})
+	histogram.Record(50 * time.Millisecond)
+	summary, _ := ms.DistributionSummary("artifact.size", MeterOpts{BaseUnit: "bytes"})
+	summary.Record(1024)
+
+	families, err := reg.Gather()
+	if !assert.NoError(t, err) {
+		return
+	}
+	byName := map[string]*dto.MetricFamily{}
+	for _, f := range families {
+		byName[f.GetName()] = f
+	}
+
+	cases := map[string]struct {
+		name string
+		help string
+		kind dto.MetricType
+	}{
+		"counter": {
+			name: "app_pipelines_started",
+			help: "Pipelines started",
+			kind: dto.MetricType_COUNTER,
+		},
+		"gauge with base unit": {
+			name: "app_queue_size_tasks",
+			help: "Tasks waiting to run",
+			kind: dto.MetricType_GAUGE,
+		},
+		"timer": {
+			name: "app_stage_duration_milliseconds",
+			help: "Time spent running stages",
+			kind: dto.MetricType_SUMMARY,
+		},
+		"timer with histogram": {
+			name: "app_task_duration_milliseconds",
+			help: "app_task_duration_milliseconds",
+			kind: dto.MetricType_HISTOGRAM,
+		},
+		"distribution summary": {
+			name: "app_artifact_size_bytes",
+			help: "app_artifact_size_bytes",
+			kind: dto.MetricType_SUMMARY,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			f := byName[c.name]
+			if !assert.NotNil(t, f) {
+				return
+			}
+			assert.Equal(t, c.help, f.GetHelp())
+			assert.Equal(t, c.kind, f.GetType())
+			labels := map[string]string{}
+			for _, l := range f.GetMetric()[0].GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			assert.Equal(t, map[string]string{"appName": "app"}, labels)
+		})
+	}
+}
+
+func TestLongTaskTimer(t *testing.T) {
+	ms, _ := newTestMetricsServer(t)
+	ltt, err := ms.LongTaskTimer("pipelines.running", MeterOpts{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	first := ltt.Start()
+	second := ltt.Start()
+	assert.Equal(t, 2, ltt.ActiveTasks())
+
+	first.start = time.Now().Add(-time.Minute)
+	second.start = time.Now().Add(-time.Second)
+	assert.GreaterOrEqual(t, int64(ltt.Duration()), int64(61*time.Second))
+	assert.GreaterOrEqual(t, int64(ltt.Max()), int64(time.Minute))
+
+	first.Stop()
+	first.Stop()
+	assert.Equal(t, 1, ltt.ActiveTasks())
+	assert.Less(t, int64(ltt.Max()), int64(time.Minute))
+	second.Stop()
+	assert.Equal(t, 0, ltt.ActiveTasks())
+	assert.Equal(t, time.Duration(0), ltt.Max())
+
+	ltt.Record(func() {
+		assert.Equal(t, 1, ltt.ActiveTasks())
+	})
+	assert.Equal(t, 0, ltt.ActiveTasks())
+
+	ctx, cancel := ltt.StartContext(context.Background())
+	assert.Equal(t, 1, ltt.ActiveTasks())
+	cancel()
+	<-ctx.Done()
+	assert.Eventually(t, func() bool { return ltt.ActiveTasks() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestLongTaskTimer_Prometheus(t *testing.T) {
+	reg := prom.NewRegistry()
+	ms, err := NewDefaultMetricsServer(MetricsServerConfig{ServiceName: "app", Registry: reg})
+	if !assert.NoError(t, err) {
+		return
+	}
+	ltt, err := ms.LongTaskTimer("bakes.running", MeterOpts{Description: "Bakes in progress"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	again, err := ms.LongTaskTimer("bakes.running", MeterOpts{})
+	assert.NoError(t, err)
+	assert.Same(t, ltt, again)
+
+	sample := ltt.Start()
+	sample.start = time.Now().Add(-time.Minute)
+	defer sample.Stop()
+
+	families, err := reg.Gather()
+	if !assert.NoError(t, err) {
+		return
+	}
+	values := map[string]float64{}
+	for _, f := range families {
+		if !strings.HasPrefix(f.GetName(), "app_bakes_running") {
+			continue
+		}
+		assert.Equal(t, dto.MetricType_GAUGE, f.GetType(), f.GetName())
+		assert.Equal(t, "Bakes in progress", f.GetHelp(), f.GetName())
+		values[f.GetName()] = f.GetMetric()[0].GetGauge().GetValue()
+	}
+	assert.Equal(t, 1.0, values["app_bakes_running_active_count"])
+	assert.GreaterOrEqual(t, values["app_bakes_running_duration_sum"], 60000.0)
+	assert.GreaterOrEqual(t, values["app_bakes_running_duration_max"], 60000.0)
+}
+
+func TestMetricsServer_GaugeFunc(t *testing.T) {
+	reg := prom.NewRegistry()
+	ms, err := NewDefaultMetricsServer(MetricsServerConfig{ServiceName: "app", Registry: reg})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	depth := 3
+	gauge, err := ms.GaugeFunc("queue.depth", MeterOpts{Description: "Tasks waiting to run", BaseUnit: "tasks"}, func() float64 {
+		return float64(depth)
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = ms.GaugeFunc("queue.depth", MeterOpts{BaseUnit: "tasks"}, func() float64 { return 0 })
+	assert.EqualError(t, err, "gauge queue.depth is already registered with these labels")
+
+	gaugeValue := func() (float64, bool) {
+		families, err := reg.Gather()
+		assert.NoError(t, err)
+		for _, f := range families {
+			if f.GetName() == "app_queue_depth_tasks" {
+				assert.Equal(t, "Tasks waiting to run", f.GetHelp())
+				return f.GetMetric()[0].GetGauge().GetValue(), true
+			}
+		}
+		return 0, false
+	}
+
+	v, ok := gaugeValue()
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v)
+	depth = 5
+	v, _ = gaugeValue()
+	assert.Equal(t, 5.0, v)
+
+	gauge.Remove()
+	_, ok = gaugeValue()
+	assert.False(t, ok)
+
+	replacement, err := ms.GaugeFunc("queue.depth", MeterOpts{BaseUnit: "tasks"}, func() float64 { return 1 })
+	if !assert.NoError(t, err) {
+		return
+	}
+	gauge.Remove()
+	v, ok = gaugeValue()
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, v)
+	replacement.Remove()
+}
+
+func TestMetricsServer_GaugeFuncPanics(t *testing.T) {
+	reg := prom.NewRegistry()
+	ms, err := NewDefaultMetricsServer(MetricsServerConfig{ServiceName: "app", Registry: reg})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = ms.GaugeFunc("pool.size", MeterOpts{}, func() float64 { panic("pool closed") })
+	assert.NoError(t, err)
+	_, err = ms.GaugeFunc("queue.depth", MeterOpts{}, func() float64 { return 3 })
+	assert.NoError(t, err)
+
+	families, err := reg.Gather()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "gauge pool.size panicked: pool closed")
+	}
+	var names []string
+	for _, f := range families {
+		if strings.HasPrefix(f.GetName(), "app_pool") || strings.HasPrefix(f.GetName(), "app_queue") {
+			names = append(names, f.GetName())
+		}
+	}
+	assert.Equal(t, []string{"app_queue_depth"}, names)
+}
+
+func TestLongTaskTimer_OtherSinks(t *testing.T) {
+	reg := prom.NewRegistry()
+	ms, err := NewDefaultMetricsServer(MetricsServerConfig{
+		ServiceName:       "app",
+		Registry:          reg,
+		Sinks:             []SinkConfig{{Type: PrometheusSink}, {Type: InmemSink}},
+		GaugeFuncInterval: 10 * time.Millisecond,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ms.Shutdown(context.Background())
+	ltt, err := ms.LongTaskTimer("bakes.running", MeterOpts{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	sample := ltt.Start()
+	sample.start = time.Now().Add(-time.Minute)
+	defer sample.Stop()
+
+	gauge := func(name string) float32 {
+		for _, interval := range ms.InmemSink().Data() {
+			interval.RLock()
+			for _, g := range interval.Gauges {
+				if g.Name == name {
+					interval.RUnlock()
+					return g.Value
+				}
+			}
+			interval.RUnlock()
+		}
+		return 0
+	}
+	assert.Eventually(t, func() bool {
+		return gauge("app.bakes.running.duration.max") >= 60000 && gauge("app.bakes.running.duration.sum") >= 60000
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, float32(1), gauge("app.bakes.running.active.count"))
+
+	// Prometheus evaluates the durations itself rather than recording the pushed samples
+	_, err = reg.Gather()
+	assert.NoError(t, err)
+}
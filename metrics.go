@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
@@ -16,7 +17,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/armon/go-metrics"
-	"github.com/armon/go-metrics/prometheus"
 )
 
 var (
@@ -31,13 +31,69 @@ type MetricsServerConfig struct {
 	Ctx           context.Context
 	DefaultLabels []string
 	Registry      prom.Registerer
+
+	// HealthPath is where the aggregated health report is served, the
+	// liveness and readiness groups are served beneath it
+	HealthPath string
+	// Health supplies the indicators reported by the health endpoints,
+	// an empty registry is created when one isn't provided
+	Health *HealthRegistry
+
+	// Histograms declares the timers and samples exposed as Prometheus
+	// histograms. DefaultHistograms are used when nil
+	Histograms []HistogramConfig
+
+	// Sinks declares where metrics are sent. DefaultSinks are used when nil.
+	// The Prometheus endpoint is only served when a prometheus sink is declared
+	Sinks []SinkConfig
+
+	// MaxLabelValues caps the distinct values each label can take per metric,
+	// further values are recorded as OverflowLabelValue. DefaultMaxLabelValues
+	// is used when zero, a negative value disables the cap
+	MaxLabelValues int
+
+	// URIMapper maps requests whose route template isn't known to their uri
+	// label. It is the default of RequestMetricsMiddleware and InstrumentMuxRouter,
+	// a mapper passed to InstrumentWith or InstrumentMuxRouterWith takes precedence
+	URIMapper URIMapperFunc
+
+	// GaugeFuncInterval is how often function gauges are sampled and sent to
+	// the sinks other than Prometheus, which evaluates them when scraped.
+	// DefaultGaugeFuncInterval is used when zero
+	GaugeFuncInterval time.Duration
+}
+
+// DefaultGaugeFuncInterval is how often function gauges are sampled by default
+var DefaultGaugeFuncInterval = 10 * time.Second
+
+// MetricsSettings is used to extract the metrics configuration
+// from the application's configuration
+type MetricsSettings struct {
+	Metrics struct {
+		Histograms     []HistogramConfig  `yaml:"histograms"`
+		Sinks          []SinkConfig       `yaml:"sinks"`
+		MaxLabelValues int                `yaml:"maxLabelValues"`
+		URIPatterns    []URIPatternConfig `yaml:"uriPatterns"`
+		// GaugeFuncInterval is how often function gauges are sent to the
+		// sinks other than Prometheus
+		GaugeFuncInterval time.Duration `yaml:"gaugeFuncInterval"`
+	} `yaml:"metrics"`
 }
 
 type MetricsServer struct {
 	metrics       *metrics.Metrics
 	server        *http.Server
+	mux           *http.ServeMux
+	health        *HealthRegistry
+	inmem         *metrics.InmemSink
+	shutdownSinks []func()
+	uriMapper     URIMapperFunc
+	meters        *meterRegistry
 	ctx           context.Context
 	defaultLabels []metrics.Label
+
+	routersMu sync.Mutex
+	templated map[*mux.Router]bool
 }
 
 func NewDefaultMetricsServer(cfg MetricsServerConfig) (*MetricsServer, error) {
@@ -45,7 +101,8 @@ func NewDefaultMetricsServer(cfg MetricsServerConfig) (*MetricsServer, error) {
 		return nil, errors.New("metrics server requires an application name be provided by configuration")
 	}
 
-	sink, err := sinkFromConfig(cfg)
+	meters := newMeterRegistry()
+	sinks, err := sinksFromConfig(cfg, meters)
 	if err != nil {
 		return nil, err
 	}
@@ -59,8 +116,19 @@ func NewDefaultMetricsServer(cfg MetricsServerConfig) (*MetricsServer, error) {
 		ProfileInterval:      time.Second,
 		FilterDefault:        true,
 	}
+	sink := sinks.sink
+	maxLabelValues := cfg.MaxLabelValues
+	if maxLabelValues == 0 {
+		maxLabelValues = DefaultMaxLabelValues
+	}
+	if maxLabelValues > 0 {
+		sink = newCardinalityLimiter(sink, cfg.ServiceName, maxLabelValues)
+	}
 	m, err := metrics.New(mc, sink)
 	if err != nil {
+		for _, shutdown := range sinks.shutdown {
+			shutdown()
+		}
 		return nil, err
 	}
 
@@ -70,7 +138,24 @@ func NewDefaultMetricsServer(cfg MetricsServerConfig) (*MetricsServer, error) {
 	if pth == "" {
 		pth = DefaultObservabilityPath
 	}
-	mux.Handle(pth, promhttp.Handler())
+	if sinks.prometheus {
+		mux.Handle(pth, prometheusHandler(cfg.Registry))
+	}
+	if sinks.inmem != nil {
+		mux.Handle(pth+"/inmem", inmemHandler(sinks.inmem))
+	}
+
+	health := cfg.Health
+	if health == nil {
+		health = NewHealthRegistry()
+	}
+	healthPath := cfg.HealthPath
+	if healthPath == "" {
+		healthPath = DefaultHealthPath
+	}
+	mux.Handle(healthPath, health.HealthHandler(""))
+	mux.Handle(healthPath+"/"+LivenessGroup, health.HealthHandler(LivenessGroup))
+	mux.Handle(healthPath+"/"+ReadinessGroup, health.HealthHandler(ReadinessGroup))
 
 	addr := cfg.Addr
 	if addr == "" {
@@ -89,19 +174,33 @@ func NewDefaultMetricsServer(cfg MetricsServerConfig) (*MetricsServer, error) {
 	ms := &MetricsServer{
 		metrics:       m,
 		server:        server,
+		mux:           mux,
+		health:        health,
+		inmem:         sinks.inmem,
+		shutdownSinks: sinks.shutdown,
+		uriMapper:     cfg.URIMapper,
+		meters:        meters,
 		ctx:           ctx,
 		defaultLabels: defaultLabels,
 	}
+	if sinks.push {
+		interval := cfg.GaugeFuncInterval
+		if interval <= 0 {
+			interval = DefaultGaugeFuncInterval
+		}
+		// stop pushing before the sinks are shut down
+		ms.shutdownSinks = append([]func(){ms.pushGaugeFuncs(interval)}, ms.shutdownSinks...)
+	}
 	return ms, nil
 }
 
-func sinkFromConfig(cfg MetricsServerConfig) (*prometheus.PrometheusSink, error) {
-	if cfg.Registry != nil {
-		opts := prometheus.DefaultPrometheusOpts
-		opts.Registerer = cfg.Registry
-		return prometheus.NewPrometheusSinkFrom(opts)
+// prometheusHandler serves the metrics gathered by reg when it is also a
+// Gatherer, and the default registry's otherwise
+func prometheusHandler(reg prom.Registerer) http.Handler {
+	if g, ok := reg.(prom.Gatherer); ok {
+		return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
 	}
-	return prometheus.NewPrometheusSink()
+	return promhttp.Handler()
 }
 
 func labelsFromPairs(a []string) []metrics.Label {
@@ -117,6 +216,17 @@ func (ms *MetricsServer) MetricsRegistry() *metrics.Metrics {
 	return ms.metrics
 }
 
+// HealthRegistry returns the registry backing the metrics server's health endpoints
+func (ms *MetricsServer) HealthRegistry() *HealthRegistry {
+	return ms.health
+}
+
+// Handle mounts an additional handler on the metrics server, this is useful
+// for exposing operational endpoints away from the application's own port
+func (ms *MetricsServer) Handle(pattern string, handler http.Handler) {
+	ms.mux.Handle(pattern, handler)
+}
+
 func (ms *MetricsServer) WatchForShutdown() {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
@@ -134,92 +244,201 @@ func (ms *MetricsServer) WatchForShutdown() {
 
 func (ms *MetricsServer) Start() error {
 	go func() {
-		for {
-			select {
-			case <-ms.ctx.Done():
-				cancelContext, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
-				defer func() {
-					cancelFunc()
-				}()
-				ms.server.Shutdown(cancelContext)
-			}
-		}
+		<-ms.ctx.Done()
+		cancelContext, cancelFunc := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFunc()
+		ms.server.Shutdown(cancelContext)
 	}()
 
 	return ms.server.ListenAndServe()
 }
 
+// InmemSink returns the in-memory sink, or nil when one isn't configured
+func (ms *MetricsServer) InmemSink() *metrics.InmemSink {
+	return ms.inmem
+}
+
+// Shutdown gracefully stops the metrics server, flushes the sinks and
+// removes the Prometheus collectors from the registry
+func (ms *MetricsServer) Shutdown(ctx context.Context) error {
+	err := ms.server.Shutdown(ctx)
+	for _, shutdown := range ms.shutdownSinks {
+		shutdown()
+	}
+	return err
+}
+
 // wrappedResponseWriter is used to capture the status code
-// response so we can use it in metrics
+// and size of the response so we can use it in metrics and logs
 type wrappedResponseWriter struct {
 	http.ResponseWriter
-	statusCode int
+	statusCode   int
+	bytesWritten int
 }
 
 func (wrw *wrappedResponseWriter) WriteHeader(code int) {
-	wrw.statusCode = code
+	if wrw.statusCode == 0 {
+		wrw.statusCode = code
+	}
 	wrw.ResponseWriter.WriteHeader(code)
 }
 
+func (wrw *wrappedResponseWriter) Write(b []byte) (int, error) {
+	// writing without calling WriteHeader implies a 200
+	if wrw.statusCode == 0 {
+		wrw.statusCode = http.StatusOK
+	}
+	n, err := wrw.ResponseWriter.Write(b)
+	wrw.bytesWritten += n
+	return n, err
+}
+
+// status returns the response's status code, when nothing was
+// written net/http responds with a 200
+func (wrw *wrappedResponseWriter) status() int {
+	if wrw.statusCode == 0 {
+		return http.StatusOK
+	}
+	return wrw.statusCode
+}
+
 func wrapResponseWriter(w http.ResponseWriter) *wrappedResponseWriter {
 	return &wrappedResponseWriter{ResponseWriter: w}
 }
 
-// URIMapperFunc is used by RequestMetricsMiddleware
-// to map requests URIs to their parametrized counterpart
+// URIMapperFunc is used by RequestMetricsMiddleware to map requests URIs to
+// their parametrized counterpart when the route that handled them didn't
+// record its template, which gorilla/mux routers and ServeMux do
 type URIMapperFunc func(r *http.Request) string
 
-var MuxURIMapperFunc = func(r *http.Request) string {
-	route := mux.CurrentRoute(r)
-	if route == nil {
-		return DefaultURIMapperFunc(r)
+var MuxURIMapperFunc = muxURIMapper(DefaultURIMapperFunc)
+
+// muxURIMapper returns the path template of the gorilla/mux route that
+// matched the request, or maps it with fallback when none did
+func muxURIMapper(fallback URIMapperFunc) URIMapperFunc {
+	return func(r *http.Request) string {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			return fallback(r)
+		}
+		// TODO: should we handle the error or not?
+		t, _ := route.GetPathTemplate()
+		return t
 	}
-	// TODO: should we handle the error or not?
-	t, _ := route.GetPathTemplate()
-	return t
 }
 
-// DefaultURIMapperFunc returns the RequestURI from the URL
+// DefaultURIMapperFunc returns the path of the URL, without its query string
 var DefaultURIMapperFunc = func(r *http.Request) string {
-	return r.URL.RequestURI()
+	return r.URL.EscapedPath()
 }
 
 // RequestMetricsMiddleware instruments incoming http requests using Go's
-// default ServerMux
+// default ServerMux. Requests are recorded in the http.server.requests timer
+// with the same labels as Spring: exception, method, outcome, status and uri,
+// along with the server's default labels. URIs are mapped by the configured
+// URIMapper, use InstrumentWith to supply another one
 func (ms *MetricsServer) RequestMetricsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		wrappedWriter := wrapResponseWriter(w)
-		startTime := time.Now()
-		next.ServeHTTP(wrappedWriter, r)
-		requestLabels := requestMetricLabels(wrappedWriter, r, DefaultURIMapperFunc)
-		ms.metrics.MeasureSinceWithLabels([]string{"http.server.requests"}, startTime, requestLabels)
-	})
+	return ms.instrument(next, ms.defaultURIMapper())
+}
+
+// InstrumentWith returns middleware recording requests like RequestMetricsMiddleware
+// that maps URIs with uriMapper, e.g. one created by PatternURIMapperFunc
+func (ms *MetricsServer) InstrumentWith(uriMapper URIMapperFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return ms.instrument(next, uriMapper)
+	}
 }
 
-// InstrumentMuxRouter should be paired with a router from gorilla/mux
+// InstrumentMuxRouter should be paired with a router from gorilla/mux.
+// Requests that didn't match a route are mapped by the configured URIMapper,
+// use InstrumentMuxRouterWith to supply another one
 func (ms *MetricsServer) InstrumentMuxRouter(next http.Handler) http.Handler {
+	return ms.InstrumentMuxRouterWith(ms.defaultURIMapper())(next)
+}
+
+// InstrumentMuxRouterWith returns middleware recording requests like
+// InstrumentMuxRouter that maps URIs with uriMapper when no route matched
+func (ms *MetricsServer) InstrumentMuxRouterWith(uriMapper URIMapperFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if router, ok := next.(*mux.Router); ok {
+			// the matched route is only visible from within the router
+			ms.recordRouteTemplates(router)
+		}
+		return ms.instrument(next, muxURIMapper(uriMapper))
+	}
+}
+
+// recordRouteTemplates makes router record the template of matched routes
+// so outer middleware can report templated URIs. The middleware is only
+// installed once per router, however many times the router is instrumented
+func (ms *MetricsServer) recordRouteTemplates(router *mux.Router) {
+	ms.routersMu.Lock()
+	defer ms.routersMu.Unlock()
+	if ms.templated[router] {
+		return
+	}
+	if ms.templated == nil {
+		ms.templated = map[*mux.Router]bool{}
+	}
+	router.Use(recordRouteTemplate)
+	ms.templated[router] = true
+}
+
+// defaultURIMapper returns the configured URIMapper, or DefaultURIMapperFunc
+func (ms *MetricsServer) defaultURIMapper() URIMapperFunc {
+	if ms.uriMapper != nil {
+		return ms.uriMapper
+	}
+	return DefaultURIMapperFunc
+}
+
+func (ms *MetricsServer) instrument(next http.Handler, uriMapper URIMapperFunc) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, _ = withRequestInfo(r)
 		wrappedWriter := wrapResponseWriter(w)
 		startTime := time.Now()
 		next.ServeHTTP(wrappedWriter, r)
-		requestLabels := requestMetricLabels(wrappedWriter, r, MuxURIMapperFunc)
-		labels := append(requestLabels, ms.defaultLabels...)
+		labels := append(requestMetricLabels(wrappedWriter, r, uriMapper), ms.defaultLabels...)
 		ms.metrics.MeasureSinceWithLabels([]string{"http.server.requests"}, startTime, labels)
 	})
 }
 
 func requestMetricLabels(w *wrappedResponseWriter, r *http.Request, uriMapper func(r *http.Request) string) []metrics.Label {
-	// TODO: add the rest of the required labels
-	method := r.Method
-	uri := uriMapper(r)
-	code := w.statusCode
-	outcome := codeToOutcome(code)
+	code := w.status()
+	exception := "None"
+	if info := requestInfoFrom(r); info != nil && info.exception != "" {
+		exception = info.exception
+	}
 	return []metrics.Label{
-		{Name: "method", Value: method},
-		{Name: "uri", Value: uri},
-		{Name: "outcome", Value: outcome},
+		{Name: "exception", Value: exception},
+		{Name: "method", Value: r.Method},
+		{Name: "outcome", Value: codeToOutcome(code)},
 		{Name: "status", Value: strconv.Itoa(code)},
+		{Name: "uri", Value: requestURI(r, code, uriMapper)},
+	}
+}
+
+// requestURI returns the uri label of a request. Like Spring, the template of
+// the matched route is used and requests that didn't match a route are
+// reported as REDIRECTION or NOT_FOUND rather than by their path
+func requestURI(r *http.Request, code int, uriMapper func(r *http.Request) string) string {
+	uri := ""
+	if info := requestInfoFrom(r); info != nil {
+		uri = info.uriTemplate
+	}
+	if uri == "" {
+		switch {
+		case code >= 300 && code <= 399:
+			return "REDIRECTION"
+		case code == http.StatusNotFound:
+			return "NOT_FOUND"
+		}
+		uri = uriMapper(r)
+	}
+	if uri == "" || uri == "/" {
+		return "root"
 	}
+	return uri
 }
 
 func codeToOutcome(code int) string {
@@ -235,5 +454,5 @@ func codeToOutcome(code int) string {
 	case code >= 500 && code <= 599:
 		return "SERVER_ERROR"
 	}
-	return "UNKNOWN_STATUS"
+	return "UNKNOWN"
 }
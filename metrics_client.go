@@ -0,0 +1,68 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/armon/go-metrics"
+)
+
+type uriTemplateKey struct{}
+
+// NoURITemplate is the uri label of client requests made without a
+// template, like Micrometer, so that raw paths never become label values
+const NoURITemplate = "none"
+
+// ContextWithURITemplate returns a copy of ctx that sets the uri label of
+// http.client.requests for requests made with it, e.g.
+// "/applications/{application}". Without a template, NoURITemplate is used
+func ContextWithURITemplate(ctx context.Context, template string) context.Context {
+	return context.WithValue(ctx, uriTemplateKey{}, template)
+}
+
+func clientURITemplate(r *http.Request) string {
+	if t, ok := r.Context().Value(uriTemplateKey{}).(string); ok && t != "" {
+		return t
+	}
+	return NoURITemplate
+}
+
+// InstrumentTransport wraps next so that every outgoing request is recorded in
+// the http.client.requests timer, labeled with the method, uri template, status,
+// outcome and target host like Spring's client metrics
+func (ms *MetricsServer) InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		startTime := time.Now()
+		resp, err := next.RoundTrip(req)
+		labels := append(clientMetricLabels(req, resp, err), ms.defaultLabels...)
+		ms.metrics.MeasureSinceWithLabels([]string{"http.client.requests"}, startTime, labels)
+		return resp, err
+	})
+}
+
+// HTTPClient returns an http.Client whose requests are recorded
+// in the http.client.requests timer
+func (ms *MetricsServer) HTTPClient() *http.Client {
+	return &http.Client{Transport: ms.InstrumentTransport(http.DefaultTransport)}
+}
+
+func clientMetricLabels(req *http.Request, resp *http.Response, err error) []metrics.Label {
+	status := "CLIENT_ERROR"
+	outcome := "UNKNOWN"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		outcome = codeToOutcome(resp.StatusCode)
+	}
+	return []metrics.Label{
+		{Name: "method", Value: req.Method},
+		{Name: "uri", Value: clientURITemplate(req)},
+		{Name: "status", Value: status},
+		{Name: "outcome", Value: outcome},
+		{Name: "clientName", Value: req.URL.Hostname()},
+	}
+}
@@ -0,0 +1,61 @@
+package go_spec
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armon/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientMetricLabels(t *testing.T) {
+	cases := map[string]struct {
+		template string
+		resp     *http.Response
+		err      error
+		expected []metrics.Label
+	}{
+		"successful request with template": {
+			template: "/applications/{application}",
+			resp:     &http.Response{StatusCode: http.StatusOK},
+			expected: []metrics.Label{
+				{Name: "method", Value: "GET"},
+				{Name: "uri", Value: "/applications/{application}"},
+				{Name: "status", Value: "200"},
+				{Name: "outcome", Value: "SUCCESS"},
+				{Name: "clientName", Value: "front50"},
+			},
+		},
+		"server error without template": {
+			resp: &http.Response{StatusCode: http.StatusBadGateway},
+			expected: []metrics.Label{
+				{Name: "method", Value: "GET"},
+				{Name: "uri", Value: "none"},
+				{Name: "status", Value: "502"},
+				{Name: "outcome", Value: "SERVER_ERROR"},
+				{Name: "clientName", Value: "front50"},
+			},
+		},
+		"connection error": {
+			err: errors.New("connection refused"),
+			expected: []metrics.Label{
+				{Name: "method", Value: "GET"},
+				{Name: "uri", Value: "none"},
+				{Name: "status", Value: "CLIENT_ERROR"},
+				{Name: "outcome", Value: "UNKNOWN"},
+				{Name: "clientName", Value: "front50"},
+			},
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://front50:8080/applications/canals?expand=true", nil)
+			if c.template != "" {
+				req = req.WithContext(ContextWithURITemplate(req.Context(), c.template))
+			}
+			assert.Equal(t, c.expected, clientMetricLabels(req, c.resp, c.err))
+		})
+	}
+}
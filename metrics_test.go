@@ -0,0 +1,139 @@
+package go_spec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/armon/go-metrics"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestMetricsMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(routeTemplateMiddleware)
+	router.HandleFunc("/applications/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	})
+	router.Handle("/fail", HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return NewConflictError("conflict", nil)
+	}))
+	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+
+	cases := map[string]struct {
+		path     string
+		expected map[string]string
+	}{
+		"templated uri": {
+			path: "/applications/deck?expand=true",
+			expected: map[string]string{
+				"exception": "None", "method": "GET", "outcome": "SUCCESS", "status": "200", "uri": "/applications/{name}",
+			},
+		},
+		"exception": {
+			path: "/fail",
+			expected: map[string]string{
+				"exception": "ConflictError", "method": "GET", "outcome": "CLIENT_ERROR", "status": "409", "uri": "/fail",
+			},
+		},
+		"root": {
+			path: "/",
+			expected: map[string]string{
+				"exception": "None", "method": "GET", "outcome": "SUCCESS", "status": "204", "uri": "root",
+			},
+		},
+		"redirect": {
+			path: "/redirect",
+			expected: map[string]string{
+				"exception": "None", "method": "GET", "outcome": "REDIRECTION", "status": "302", "uri": "/redirect",
+			},
+		},
+		"not found": {
+			path: "/unknown/path",
+			expected: map[string]string{
+				"exception": "None", "method": "GET", "outcome": "CLIENT_ERROR", "status": "404", "uri": "NOT_FOUND",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ms, sink := newTestMetricsServer(t)
+			ms.defaultLabels = []metrics.Label{{Name: "app", Value: "test"}}
+			ms.RequestMetricsMiddleware(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, c.path, nil))
+
+			c.expected["app"] = "test"
+			var found bool
+			for _, interval := range sink.Data() {
+				for _, sample := range interval.Samples {
+					if sample.Name != "http.server.requests" {
+						continue
+					}
+					found = true
+					labels := map[string]string{}
+					for _, l := range sample.Labels {
+						labels[l.Name] = l.Value
+					}
+					assert.Equal(t, c.expected, labels)
+				}
+			}
+			assert.True(t, found)
+		})
+	}
+}
+
+func TestCodeToOutcome(t *testing.T) {
+	cases := map[int]string{
+		101: "INFORMATIONAL",
+		200: "SUCCESS",
+		301: "REDIRECTION",
+		404: "CLIENT_ERROR",
+		503: "SERVER_ERROR",
+		0:   "UNKNOWN",
+	}
+	for code, expected := range cases {
+		assert.Equal(t, expected, codeToOutcome(code))
+	}
+}
+
+// countRouteTemplates counts the requests seen by the route template
+// middleware installed on routers, once per installation
+func countRouteTemplates(t *testing.T) *int32 {
+	var runs int32
+	record := recordRouteTemplate
+	recordRouteTemplate = func(next http.Handler) http.Handler {
+		h := record(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&runs, 1)
+			h.ServeHTTP(w, r)
+		})
+	}
+	t.Cleanup(func() { recordRouteTemplate = record })
+	return &runs
+}
+
+func TestMetricsServer_InstrumentMuxRouterOnce(t *testing.T) {
+	runs := countRouteTemplates(t)
+	router := mux.NewRouter()
+	router.HandleFunc("/applications/{name}", func(w http.ResponseWriter, r *http.Request) {})
+
+	ms, sink := newTestMetricsServer(t)
+	ms.InstrumentMuxRouter(router)
+	ms.InstrumentMuxRouterWith(DefaultURIMapperFunc)(router).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/applications/deck", nil))
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(runs))
+	var uris []string
+	for _, interval := range sink.Data() {
+		for _, sample := range interval.Samples {
+			uris = append(uris, labelValue(sample.Labels, "uri"))
+		}
+	}
+	assert.Equal(t, []string{"/applications/{name}"}, uris)
+}
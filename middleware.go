@@ -0,0 +1,69 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/armory-io/go-spec/logging"
+	"github.com/gorilla/mux"
+)
+
+// LoggerMiddleware stores logger in each request's context so that handlers
+// can retrieve it, along with any request scoped fields, using logging.FromContext
+func LoggerMiddleware(logger logging.LeveledLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := logging.IntoContext(r.Context(), logger)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type requestInfoKey struct{}
+
+// requestInfo collects details about a request while it is handled by the
+// router so that middleware wrapping the router, which can't see which route
+// was matched, is able to report them
+type requestInfo struct {
+	uriTemplate string
+	// exception is the type of the error or panic the request failed with
+	exception string
+}
+
+// withRequestInfo returns a request carrying a requestInfo, reusing the one
+// already attached by outer middleware when present
+func withRequestInfo(r *http.Request) (*http.Request, *requestInfo) {
+	if info := requestInfoFrom(r); info != nil {
+		return r, info
+	}
+	info := &requestInfo{}
+	return r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)), info
+}
+
+func requestInfoFrom(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// setException records the exception a request failed with, if the
+// request is being handled by the ApplicationContext's middleware
+func setException(r *http.Request, exception string) {
+	if info := requestInfoFrom(r); info != nil {
+		info.exception = exception
+	}
+}
+
+// recordRouteTemplate is the middleware installed on instrumented routers,
+// tests replace it to observe how often it runs
+var recordRouteTemplate mux.MiddlewareFunc = routeTemplateMiddleware
+
+// routeTemplateMiddleware records the path template of the gorilla/mux route
+// matched for the request. It must be installed on the router with Use
+func routeTemplateMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := requestInfoFrom(r); info != nil && mux.CurrentRoute(r) != nil {
+			info.uriTemplate = MuxURIMapperFunc(r)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
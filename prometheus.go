@@ -0,0 +1,291 @@
+package go_spec
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+var forbiddenPromChars = regexp.MustCompile(`[ .=\-/]`)
+
+// summaryObjectives match the ones used by the armon Prometheus sink
+var summaryObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+// prometheusSink sits in front of the armon Prometheus sink. It records the
+// samples matching a HistogramConfig as histograms, and the meters registered
+// through the MetricsServer's typed API with their description and base unit.
+// Every other metric is handed to next. Like the armon sink, series that
+// haven't been updated within expiration are dropped, except for registered
+// meters which are kept for the lifetime of the application
+type prometheusSink struct {
+	next        metrics.MetricSink
+	serviceName string
+	histograms  []HistogramConfig
+	meters      *meterRegistry
+	expiration  time.Duration
+
+	mu     sync.Mutex
+	series map[string]*promSeries
+}
+
+type promSeries struct {
+	collectors []prom.Collector
+	observe    func(float64)
+	updatedAt  time.Time
+	persistent bool
+}
+
+func newPrometheusSink(next metrics.MetricSink, serviceName string, histograms []HistogramConfig, meters *meterRegistry, reg prom.Registerer) (*prometheusSink, error) {
+	ps := &prometheusSink{
+		next:        next,
+		serviceName: serviceName,
+		histograms:  histograms,
+		meters:      meters,
+		expiration:  60 * time.Second,
+		series:      map[string]*promSeries{},
+	}
+	if reg == nil {
+		reg = prom.DefaultRegisterer
+	}
+	return ps, reg.Register(ps)
+}
+
+func (ps *prometheusSink) SetGauge(key []string, val float32) {
+	ps.SetGaugeWithLabels(key, val, nil)
+}
+
+func (ps *prometheusSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	// function gauges are pushed for the other sinks, Prometheus
+	// evaluates them when scraped instead
+	if _, ok := ps.description(key, gaugeFuncMeter); ok {
+		return
+	}
+	d, ok := ps.description(key, gaugeMeter)
+	if !ok {
+		ps.next.SetGaugeWithLabels(key, val, labels)
+		return
+	}
+	ps.record(key, labels, float64(val), d, func(opts prom.Opts) *promSeries {
+		g := prom.NewGauge(prom.GaugeOpts(opts))
+		return &promSeries{collectors: []prom.Collector{g}, observe: g.Set}
+	})
+}
+
+func (ps *prometheusSink) EmitKey(key []string, val float32) {
+	ps.next.EmitKey(key, val)
+}
+
+func (ps *prometheusSink) IncrCounter(key []string, val float32) {
+	ps.IncrCounterWithLabels(key, val, nil)
+}
+
+func (ps *prometheusSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	d, ok := ps.description(key, counterMeter)
+	if !ok {
+		ps.next.IncrCounterWithLabels(key, val, labels)
+		return
+	}
+	ps.record(key, labels, float64(val), d, func(opts prom.Opts) *promSeries {
+		c := prom.NewCounter(prom.CounterOpts(opts))
+		return &promSeries{collectors: []prom.Collector{c}, observe: c.Add}
+	})
+}
+
+func (ps *prometheusSink) AddSample(key []string, val float32) {
+	ps.AddSampleWithLabels(key, val, nil)
+}
+
+func (ps *prometheusSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	d, described := ps.description(key, timerMeter, summaryMeter)
+	var hc HistogramConfig
+	ok := false
+	if d.histogram != nil {
+		hc, ok = *d.histogram, true
+	} else {
+		hc, ok = ps.histogramFor(key)
+	}
+	if !described && !ok {
+		ps.next.AddSampleWithLabels(key, val, labels)
+		return
+	}
+	ps.record(key, labels, float64(val), d, func(opts prom.Opts) *promSeries {
+		if !ok {
+			s := prom.NewSummary(prom.SummaryOpts{
+				Name:        opts.Name,
+				Help:        opts.Help,
+				ConstLabels: opts.ConstLabels,
+				MaxAge:      10 * time.Second,
+				Objectives:  summaryObjectives,
+			})
+			return &promSeries{collectors: []prom.Collector{s}, observe: s.Observe}
+		}
+		return newHistogramSeries(opts, hc)
+	})
+}
+
+func newHistogramSeries(opts prom.Opts, hc HistogramConfig) *promSeries {
+	h := prom.NewHistogram(prom.HistogramOpts{
+		Name:        opts.Name,
+		Help:        opts.Help,
+		ConstLabels: opts.ConstLabels,
+		Buckets:     hc.buckets(),
+	})
+	if len(hc.Percentiles) == 0 {
+		return &promSeries{collectors: []prom.Collector{h}, observe: h.Observe}
+	}
+	objectives := map[float64]float64{}
+	for _, p := range hc.Percentiles {
+		objectives[p] = 0.001
+	}
+	s := prom.NewSummary(prom.SummaryOpts{
+		Name:        opts.Name + "_percentiles",
+		Help:        opts.Help,
+		ConstLabels: opts.ConstLabels,
+		MaxAge:      10 * time.Second,
+		Objectives:  objectives,
+	})
+	return &promSeries{
+		collectors: []prom.Collector{h, s},
+		observe: func(v float64) {
+			h.Observe(v)
+			s.Observe(v)
+		},
+	}
+}
+
+// record observes val in the series of key and labels, creating it with
+// build the first time it is seen
+func (ps *prometheusSink) record(key []string, labels []metrics.Label, val float64, d meterDescription, build func(prom.Opts) *promSeries) {
+	name := promName(key, d.unit)
+	hash := meterHash(name, labels)
+
+	ps.mu.Lock()
+	s, ok := ps.series[hash]
+	if !ok {
+		help := d.help
+		if help == "" {
+			help = name
+		}
+		s = build(prom.Opts{Name: name, Help: help, ConstLabels: constLabels(labels)})
+		s.persistent = d.kind != unknownMeter
+		ps.series[hash] = s
+	}
+	s.updatedAt = time.Now()
+	ps.mu.Unlock()
+
+	s.observe(val)
+}
+
+// promName joins key into a Prometheus metric name ending with unit
+func promName(key []string, unit string) string {
+	name := forbiddenPromChars.ReplaceAllString(strings.Join(key, "_"), "_")
+	if unit != "" && !strings.HasSuffix(name, "_"+unit) {
+		name += "_" + unit
+	}
+	return name
+}
+
+func constLabels(labels []metrics.Label) prom.Labels {
+	cl := prom.Labels{}
+	for _, l := range labels {
+		cl[l.Name] = l.Value
+	}
+	return cl
+}
+
+// description returns the description of the meter registered under the
+// key's name when it is one of kinds
+func (ps *prometheusSink) description(key []string, kinds ...meterType) (meterDescription, bool) {
+	if ps.meters == nil {
+		return meterDescription{}, false
+	}
+	d, ok := ps.meters.description(stripServiceName(ps.serviceName, key))
+	if !ok {
+		return meterDescription{}, false
+	}
+	for _, kind := range kinds {
+		if d.kind == kind {
+			return d, true
+		}
+	}
+	return meterDescription{}, false
+}
+
+// histogramFor returns the config with the longest prefix matching the key's name
+func (ps *prometheusSink) histogramFor(key []string) (HistogramConfig, bool) {
+	name := stripServiceName(ps.serviceName, key)
+	var match HistogramConfig
+	found := false
+	for _, hc := range ps.histograms {
+		if strings.HasPrefix(name, hc.Prefix) && (!found || len(hc.Prefix) > len(match.Prefix)) {
+			match, found = hc, true
+		}
+	}
+	return match, found
+}
+
+// Describe is left empty, like the armon Prometheus sink, since series are
+// created as metrics are recorded
+func (ps *prometheusSink) Describe(c chan<- *prom.Desc) {
+}
+
+// Collect snapshots the live series under mu and collects them once it is
+// released, so that recording metrics doesn't wait on slow scrapes
+func (ps *prometheusSink) Collect(c chan<- prom.Metric) {
+	ps.mu.Lock()
+	now := time.Now()
+	var collectors []prom.Collector
+	for hash, s := range ps.series {
+		if !s.persistent && ps.expiration > 0 && s.updatedAt.Add(ps.expiration).Before(now) {
+			delete(ps.series, hash)
+			continue
+		}
+		collectors = append(collectors, s.collectors...)
+	}
+	ps.mu.Unlock()
+
+	for _, collector := range collectors {
+		collector.Collect(c)
+	}
+
+	if ps.meters != nil {
+		ps.collectGaugeFuncs(c)
+	}
+}
+
+// collectGaugeFuncs evaluates the function gauges, outside of mu
+// since they may take a while
+func (ps *prometheusSink) collectGaugeFuncs(c chan<- prom.Metric) {
+	for _, g := range ps.meters.gaugeFuncs() {
+		key := []string{g.name}
+		if ps.serviceName != "" {
+			key = append([]string{ps.serviceName}, key...)
+		}
+		d, _ := ps.meters.description(g.name)
+		name := promName(key, d.unit)
+		help := d.help
+		if help == "" {
+			help = name
+		}
+		desc := prom.NewDesc(name, help, nil, constLabels(g.labels))
+		c <- gaugeFuncMetric(desc, g)
+	}
+}
+
+// gaugeFuncMetric evaluates g, a panicking function is reported
+// as an invalid metric rather than crashing the scrape
+func gaugeFuncMetric(desc *prom.Desc, g *GaugeFunc) prom.Metric {
+	v, err := g.sample()
+	if err != nil {
+		return prom.NewInvalidMetric(desc, err)
+	}
+	m, err := prom.NewConstMetric(desc, prom.GaugeValue, v)
+	if err != nil {
+		return prom.NewInvalidMetric(desc, err)
+	}
+	return m
+}
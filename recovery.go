@@ -0,0 +1,75 @@
+package go_spec
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+
+	"github.com/armon/go-metrics"
+	"github.com/armory-io/go-spec/logging"
+)
+
+// RecoveryMiddleware recovers from panics raised by next. The panic is logged
+// with its stack trace, counted in the http.server.panics counter and, unless
+// next already started writing a response, answered with a 500 ErrorResponse.
+// ms may be nil, in which case the panic isn't counted
+func RecoveryMiddleware(logger logging.LeveledLogger, ms *MetricsServer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r, info := withRequestInfo(r)
+			wrappedWriter := wrapResponseWriter(w)
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// net/http uses this panic to abort a response on purpose
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				uri := info.uriTemplate
+				if uri == "" {
+					uri = r.URL.Path
+				}
+				exception := exceptionName(v)
+				info.exception = exception
+				logger.WithContext(r.Context()).WithFields(map[string]interface{}{
+					"method":    r.Method,
+					"uri":       uri,
+					"exception": exception,
+					"stack":     string(debug.Stack()),
+				}).Errorf("panic serving %s %s: %v", r.Method, uri, v)
+
+				if ms != nil {
+					labels := append([]metrics.Label{
+						{Name: "method", Value: r.Method},
+						{Name: "uri", Value: uri},
+						{Name: "exception", Value: exception},
+					}, ms.defaultLabels...)
+					ms.metrics.IncrCounterWithLabels([]string{"http.server.panics"}, 1, labels)
+				}
+
+				if wrappedWriter.statusCode == 0 {
+					// like Spring, the cause isn't exposed to the caller
+					writeError(wrappedWriter, r, http.StatusInternalServerError, "")
+				}
+			}()
+			next.ServeHTTP(wrappedWriter, r)
+		})
+	}
+}
+
+// exceptionName returns the unqualified type name of v,
+// e.g. "PathError" for a *fs.PathError
+func exceptionName(v interface{}) string {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return fmt.Sprintf("%T", v)
+}
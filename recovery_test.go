@@ -0,0 +1,81 @@
+package go_spec
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	cases := map[string]struct {
+		handler        http.HandlerFunc
+		expectedStatus int
+		expectedBody   bool
+		expectedLog    []string
+		expectedPanics int
+	}{
+		"no panic": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		"panic before writing": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				panic(errors.New("boom"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   true,
+			expectedLog:    []string{"panic serving GET /applications/spinnaker: boom", "exception=errorString", "stack="},
+			expectedPanics: 1,
+		},
+		"panic after writing": {
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				panic("boom")
+			},
+			expectedStatus: http.StatusOK,
+			expectedLog:    []string{"panic serving GET /applications/spinnaker: boom", "exception=string"},
+			expectedPanics: 1,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			logger, buf := newBufferedLogger(t)
+			ms, sink := newTestMetricsServer(t)
+			h := RecoveryMiddleware(logger, ms)(c.handler)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/applications/spinnaker", nil))
+
+			assert.Equal(t, c.expectedStatus, rec.Code)
+			if c.expectedBody {
+				var body ErrorResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+				assert.Equal(t, http.StatusInternalServerError, body.Status)
+				assert.Equal(t, "Internal Server Error", body.Error)
+				assert.Equal(t, "/applications/spinnaker", body.Path)
+				assert.False(t, body.Timestamp.IsZero())
+			}
+			for _, l := range c.expectedLog {
+				assert.Contains(t, buf.String(), l)
+			}
+			assert.Equal(t, c.expectedPanics, counterTotal(sink, "http.server.panics"))
+		})
+	}
+}
+
+func TestRecoveryMiddleware_AbortHandler(t *testing.T) {
+	logger, _ := newBufferedLogger(t)
+	h := RecoveryMiddleware(logger, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -0,0 +1,100 @@
+package go_spec
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/armory-io/go-spec/logging"
+)
+
+// Headers used by Spinnaker services to correlate requests between hops
+const (
+	SpinnakerRequestIDHeader = "X-SPINNAKER-REQUEST-ID"
+	RequestIDHeader          = "X-Request-Id"
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID. The
+// ID is also attached to log messages by LeveledLogger.WithContext
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return logging.ContextWithField(ctx, logging.RequestIDField, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, or an empty
+// string when there is none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDMiddleware reads the request ID from the X-SPINNAKER-REQUEST-ID or
+// X-Request-Id headers, generating one if neither is set. The ID is stored in
+// the request's context and echoed on the response
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(SpinnakerRequestIDHeader)
+		if id == "" {
+			id = r.Header.Get(RequestIDHeader)
+		}
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(SpinnakerRequestIDHeader, id)
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// PropagateRequestID sets the request ID headers on an outgoing request from
+// the request ID stored in its context. Headers that are already set are kept
+func PropagateRequestID(req *http.Request) {
+	id := RequestIDFromContext(req.Context())
+	if id == "" {
+		return
+	}
+	if req.Header.Get(SpinnakerRequestIDHeader) == "" {
+		req.Header.Set(SpinnakerRequestIDHeader, id)
+	}
+	if req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+}
+
+// RequestIDTransport forwards the request ID stored in each outgoing
+// request's context to the downstream service
+func RequestIDTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if RequestIDFromContext(req.Context()) == "" {
+			return next.RoundTrip(req)
+		}
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		PropagateRequestID(req)
+		return next.RoundTrip(req)
+	})
+}
+
+// roundTripperFunc allows plain functions to be used as an http.RoundTripper
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newRequestID generates a random (version 4) UUID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
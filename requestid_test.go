@@ -0,0 +1,75 @@
+package go_spec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armory-io/go-spec/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	cases := map[string]struct {
+		headers    map[string]string
+		expectedID string
+	}{
+		"uses spinnaker header": {
+			headers:    map[string]string{SpinnakerRequestIDHeader: "spin-id", RequestIDHeader: "other-id"},
+			expectedID: "spin-id",
+		},
+		"falls back to x-request-id": {
+			headers:    map[string]string{RequestIDHeader: "other-id"},
+			expectedID: "other-id",
+		},
+		"generates an id": {
+			headers: map[string]string{},
+		},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			var ctxID string
+			var fields map[string]interface{}
+			h := RequestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = RequestIDFromContext(r.Context())
+				fields = logging.FieldsFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if c.expectedID != "" {
+				assert.Equal(t, c.expectedID, ctxID)
+			} else {
+				assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", ctxID)
+			}
+			assert.Equal(t, ctxID, rec.Header().Get(SpinnakerRequestIDHeader))
+			assert.Equal(t, ctxID, rec.Header().Get(RequestIDHeader))
+			assert.Equal(t, ctxID, fields[logging.RequestIDField])
+		})
+	}
+}
+
+func TestRequestIDTransport(t *testing.T) {
+	var received http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer downstream.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, downstream.URL, nil)
+	req = req.WithContext(ContextWithRequestID(req.Context(), "abc-123"))
+	client := &http.Client{Transport: RequestIDTransport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, "abc-123", received.Get(SpinnakerRequestIDHeader))
+	assert.Equal(t, "abc-123", received.Get(RequestIDHeader))
+	assert.Empty(t, req.Header.Get(SpinnakerRequestIDHeader), "caller's request must not be modified")
+}
@@ -0,0 +1,122 @@
+package go_spec
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	tlsutil "github.com/armory/go-yaml-tools/pkg/tls"
+	"github.com/armory/go-yaml-tools/pkg/tls/server"
+)
+
+// webServer serves the application's handler as configured by the `server`
+// block, like go-yaml-tools' server. Its http.Server is created under a lock
+// and a shutdown that happens before Start is remembered, so the server can
+// be stopped at any point, including before it has started listening
+type webServer struct {
+	config server.ServerConfig
+
+	mu     sync.Mutex
+	server *http.Server
+	closed bool
+}
+
+func newWebServer(config server.ServerConfig) *webServer {
+	return &webServer{config: config}
+}
+
+// Start serves handler until the server is shut down, in which case
+// http.ErrServerClosed is returned, including when Shutdown was called first
+func (ws *webServer) Start(handler http.Handler) error {
+	var tlsConfig *tls.Config
+	if ws.config.Ssl.Enabled {
+		var err error
+		if tlsConfig, err = ws.tlsConfig(); err != nil {
+			return err
+		}
+	}
+
+	ws.mu.Lock()
+	if ws.closed {
+		ws.mu.Unlock()
+		return http.ErrServerClosed
+	}
+	srv := &http.Server{
+		Addr:      ws.config.GetAddr(),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+	ws.server = srv
+	ws.mu.Unlock()
+
+	if tlsConfig != nil {
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+// Shutdown gracefully stops the server, a server that hasn't started
+// will never start
+func (ws *webServer) Shutdown(ctx context.Context) error {
+	ws.mu.Lock()
+	ws.closed = true
+	srv := ws.server
+	ws.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+// tlsConfig loads the server certificate and, when clients must present
+// one, the certificate authorities client certificates are verified with
+func (ws *webServer) tlsConfig() (*tls.Config, error) {
+	ssl := ws.config.Ssl
+	cert, err := tlsutil.GetX509KeyPair(ssl.CertFile, ssl.KeyFile, ssl.KeyPassword)
+	if err != nil {
+		return nil, fmt.Errorf("error with certificate file %s: %w", ssl.CertFile, err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	clientAuth := clientAuthType(ssl.ClientAuth)
+	if clientAuth == tls.NoClientCert {
+		return tlsConfig, nil
+	}
+	// the server certificate may be a combined PEM including its CA
+	caFile := ssl.CAcertFile
+	if caFile == "" {
+		caFile = ssl.CertFile
+	} else if err := tlsutil.CheckFileExists(caFile); err != nil {
+		return nil, fmt.Errorf("error with certificate authority file %s: %w", caFile, err)
+	}
+	caCert, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCert)
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = clientAuth
+	return tlsConfig, nil
+}
+
+func clientAuthType(t server.ClientAuthType) tls.ClientAuthType {
+	switch t {
+	case server.ClientAuthWant:
+		return tls.VerifyClientCertIfGiven
+	case server.ClientAuthNeed:
+		return tls.RequireAndVerifyClientCert
+	case server.ClientAuthAny:
+		return tls.RequireAnyClientCert
+	case server.ClientAuthRequest:
+		return tls.RequestClientCert
+	}
+	return tls.NoClientCert
+}
@@ -0,0 +1,26 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/armory/go-yaml-tools/pkg/tls/server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebServer_ShutdownBeforeStart(t *testing.T) {
+	ws := newWebServer(server.ServerConfig{Host: "127.0.0.1"})
+	assert.NoError(t, ws.Shutdown(context.Background()))
+	assert.Equal(t, http.ErrServerClosed, ws.Start(http.NotFoundHandler()))
+}
+
+func TestWebServer_Shutdown(t *testing.T) {
+	ws := newWebServer(server.ServerConfig{Host: "127.0.0.1"})
+	errs := make(chan error, 1)
+	go func() {
+		errs <- ws.Start(http.NotFoundHandler())
+	}()
+	assert.NoError(t, ws.Shutdown(context.Background()))
+	assert.Equal(t, http.ErrServerClosed, <-errs)
+}
@@ -0,0 +1,153 @@
+package go_spec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ServiceConfig describes a downstream Spinnaker service as configured
+// under `services.<name>` in spinnaker.yml
+type ServiceConfig struct {
+	BaseURL string `yaml:"baseUrl"`
+	// Enabled is nil when the service doesn't set it, in which case
+	// the service is considered enabled if it has a base URL
+	Enabled *bool `yaml:"enabled"`
+}
+
+// IsEnabled reports whether the service can be called
+func (sc ServiceConfig) IsEnabled() bool {
+	if sc.Enabled != nil {
+		return *sc.Enabled && sc.BaseURL != ""
+	}
+	return sc.BaseURL != ""
+}
+
+// ServiceRegistry resolves the base URLs of downstream Spinnaker services,
+// such as front50, clouddriver, orca and fiat, and hands out clients for them
+type ServiceRegistry struct {
+	services  map[string]ServiceConfig
+	newClient func() *http.Client
+
+	mu      sync.Mutex
+	clients map[string]*ServiceClient
+}
+
+// NewServiceRegistry creates a registry from the raw `services` block of the
+// configuration. Entries that aren't objects are ignored. newClient is used to
+// create the client for each service, http.DefaultClient is used when it is nil
+func NewServiceRegistry(raw map[string]interface{}, newClient func() *http.Client) (*ServiceRegistry, error) {
+	if newClient == nil {
+		newClient = func() *http.Client { return http.DefaultClient }
+	}
+	services := map[string]ServiceConfig{}
+	for name, v := range raw {
+		if v == nil || reflect.TypeOf(v).Kind() != reflect.Map {
+			continue
+		}
+		var sc ServiceConfig
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			Result:           &sc,
+			WeaklyTypedInput: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := decoder.Decode(v); err != nil {
+			return nil, fmt.Errorf("invalid configuration for service %s: %w", name, err)
+		}
+		sc.BaseURL = strings.TrimSuffix(sc.BaseURL, "/")
+		services[strings.ToLower(name)] = sc
+	}
+	return &ServiceRegistry{
+		services:  services,
+		newClient: newClient,
+		clients:   map[string]*ServiceClient{},
+	}, nil
+}
+
+// ServicesSettings is used to extract the `services` block
+// from the application's configuration
+type ServicesSettings struct {
+	Services map[string]interface{} `yaml:"services"`
+}
+
+// Names returns the name of every configured service, sorted
+func (sr *ServiceRegistry) Names() []string {
+	names := make([]string, 0, len(sr.services))
+	for name := range sr.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Service returns the configuration of the named service
+func (sr *ServiceRegistry) Service(name string) (ServiceConfig, bool) {
+	sc, ok := sr.services[strings.ToLower(name)]
+	return sc, ok
+}
+
+// Enabled reports whether the named service is configured and enabled
+func (sr *ServiceRegistry) Enabled(name string) bool {
+	sc, ok := sr.Service(name)
+	return ok && sc.IsEnabled()
+}
+
+// BaseURL returns the base URL of the named service. An error is returned if
+// the service isn't configured or is disabled
+func (sr *ServiceRegistry) BaseURL(name string) (string, error) {
+	sc, ok := sr.Service(name)
+	if !ok {
+		return "", fmt.Errorf("service %s is not configured", name)
+	}
+	if !sc.IsEnabled() {
+		return "", fmt.Errorf("service %s is not enabled", name)
+	}
+	return sc.BaseURL, nil
+}
+
+// Client returns the client for the named service. Clients are created once
+// and reused. An error is returned if the service isn't configured or is disabled
+func (sr *ServiceRegistry) Client(name string) (*ServiceClient, error) {
+	baseURL, err := sr.BaseURL(name)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.ToLower(name)
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if c, ok := sr.clients[key]; ok {
+		return c, nil
+	}
+	c := &ServiceClient{Client: sr.newClient(), BaseURL: baseURL}
+	sr.clients[key] = c
+	return c, nil
+}
+
+// ServiceClient makes requests to a single downstream service
+type ServiceClient struct {
+	*http.Client
+	BaseURL string
+}
+
+// NewRequest creates a request for path relative to the service's base URL.
+// The path is also recorded as the request's uri template, so it should be
+// parametrized, e.g. "/applications/{application}", with params holding the
+// value of each placeholder
+func (sc *ServiceClient) NewRequest(ctx context.Context, method, path string, params map[string]string, body io.Reader) (*http.Request, error) {
+	expanded := path
+	for k, v := range params {
+		expanded = strings.Replace(expanded, "{"+k+"}", url.PathEscape(v), -1)
+	}
+	ctx = ContextWithURITemplate(ctx, path)
+	return http.NewRequestWithContext(ctx, method, sc.BaseURL+expanded, body)
+}
@@ -0,0 +1,94 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceRegistry(t *testing.T) {
+	sr, err := NewServiceRegistry(map[string]interface{}{
+		"front50": map[string]interface{}{
+			"baseUrl": "http://front50:8080/",
+		},
+		"fiat": map[string]interface{}{
+			"baseUrl": "http://fiat:7003",
+			"enabled": "false",
+		},
+		"Clouddriver": map[string]interface{}{
+			"baseUrl": "http://clouddriver:7002",
+			"enabled": true,
+		},
+		"kayenta": map[string]interface{}{
+			"enabled": true,
+		},
+		"spinnaker": "not a service",
+	}, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := map[string]struct {
+		service         string
+		expectedBaseURL string
+		expectedErr     string
+	}{
+		"enabled by default":       {service: "front50", expectedBaseURL: "http://front50:8080"},
+		"explicitly enabled":       {service: "clouddriver", expectedBaseURL: "http://clouddriver:7002"},
+		"case insensitive":         {service: "CLOUDDRIVER", expectedBaseURL: "http://clouddriver:7002"},
+		"disabled":                 {service: "fiat", expectedErr: "service fiat is not enabled"},
+		"enabled without base url": {service: "kayenta", expectedErr: "service kayenta is not enabled"},
+		"not configured":           {service: "orca", expectedErr: "service orca is not configured"},
+	}
+	for testName, c := range cases {
+		t.Run(testName, func(t *testing.T) {
+			baseURL, err := sr.BaseURL(c.service)
+			if c.expectedErr != "" {
+				assert.EqualError(t, err, c.expectedErr)
+				assert.False(t, sr.Enabled(c.service))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, c.expectedBaseURL, baseURL)
+			assert.True(t, sr.Enabled(c.service))
+		})
+	}
+	assert.Equal(t, []string{"clouddriver", "fiat", "front50", "kayenta"}, sr.Names())
+}
+
+func TestServiceClient_NewRequest(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+	}))
+	defer server.Close()
+
+	sr, err := NewServiceRegistry(map[string]interface{}{
+		"front50": map[string]interface{}{"baseUrl": server.URL},
+	}, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	client, err := sr.Client("front50")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	same, _ := sr.Client("front50")
+	assert.Same(t, client, same)
+
+	req, err := client.NewRequest(context.Background(), http.MethodGet, "/applications/{application}",
+		map[string]string{"application": "my app"}, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, "/applications/{application}", clientURITemplate(req))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	assert.Equal(t, "/applications/my%20app", path)
+}
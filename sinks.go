@@ -0,0 +1,175 @@
+package go_spec
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/armon/go-metrics/datadog"
+	"github.com/armon/go-metrics/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric sinks supported by SinkConfig
+const (
+	PrometheusSink = "prometheus"
+	StatsdSink     = "statsd"
+	DogStatsdSink  = "dogstatsd"
+	InmemSink      = "inmem"
+)
+
+// DefaultStatsdAddr is the address of the local StatsD or DogStatsD agent
+var DefaultStatsdAddr = "localhost:8125"
+
+// SinkConfig declares a destination metrics are sent to
+type SinkConfig struct {
+	// Type is one of "prometheus", "statsd", "dogstatsd" or "inmem"
+	Type string `yaml:"type"`
+	// Address is the host:port of the StatsD or DogStatsD agent,
+	// DefaultStatsdAddr is used when empty
+	Address string `yaml:"address"`
+	// Hostname is reported by the DogStatsD sink
+	Hostname string `yaml:"hostname"`
+	// Tags are added to every metric sent by the DogStatsD sink, e.g. "env:prod"
+	Tags []string `yaml:"tags"`
+	// Interval and Retain configure the aggregation window of the
+	// in-memory sink and how long windows are kept
+	Interval time.Duration `yaml:"interval"`
+	Retain   time.Duration `yaml:"retain"`
+}
+
+// DefaultSinks only exposes metrics to Prometheus
+func DefaultSinks() []SinkConfig {
+	return []SinkConfig{{Type: PrometheusSink}}
+}
+
+// metricSinks are the sinks created from a MetricsServerConfig
+type metricSinks struct {
+	sink       metrics.MetricSink
+	prometheus bool
+	// push is set when a sink other than Prometheus is declared, those
+	// only see the function gauges that are pushed to them
+	push     bool
+	inmem    *metrics.InmemSink
+	shutdown []func()
+}
+
+func sinksFromConfig(cfg MetricsServerConfig, meters *meterRegistry) (*metricSinks, error) {
+	configs := cfg.Sinks
+	if configs == nil {
+		configs = DefaultSinks()
+	}
+	ms := &metricSinks{}
+	// release the sinks created so far when a later one can't be
+	fail := func(err error) (*metricSinks, error) {
+		for _, shutdown := range ms.shutdown {
+			shutdown()
+		}
+		return nil, err
+	}
+	fanout := metrics.FanoutSink{}
+	for _, sc := range configs {
+		addr := sc.Address
+		if addr == "" {
+			addr = DefaultStatsdAddr
+		}
+		switch strings.ToLower(sc.Type) {
+		case PrometheusSink:
+			if ms.prometheus {
+				return fail(fmt.Errorf("the prometheus sink can only be declared once"))
+			}
+			sink, unregister, err := prometheusSinkFromConfig(cfg, meters)
+			if err != nil {
+				return fail(err)
+			}
+			ms.prometheus = true
+			ms.shutdown = append(ms.shutdown, unregister)
+			fanout = append(fanout, sink)
+		case StatsdSink:
+			sink, err := metrics.NewStatsdSink(addr)
+			if err != nil {
+				return fail(err)
+			}
+			ms.shutdown = append(ms.shutdown, sink.Shutdown)
+			fanout = append(fanout, sink)
+			ms.push = true
+		case DogStatsdSink:
+			sink, err := datadog.NewDogStatsdSink(addr, sc.Hostname)
+			if err != nil {
+				return fail(err)
+			}
+			sink.SetTags(sc.Tags)
+			fanout = append(fanout, sink)
+			ms.push = true
+		case InmemSink:
+			if ms.inmem != nil {
+				return fail(fmt.Errorf("the inmem sink can only be declared once"))
+			}
+			interval, retain := sc.Interval, sc.Retain
+			if interval <= 0 {
+				interval = 10 * time.Second
+			}
+			if retain <= 0 {
+				retain = time.Minute
+			}
+			ms.inmem = metrics.NewInmemSink(interval, retain)
+			fanout = append(fanout, ms.inmem)
+			ms.push = true
+		default:
+			return fail(fmt.Errorf("unknown metrics sink: %q", sc.Type))
+		}
+	}
+
+	switch len(fanout) {
+	case 0:
+		ms.sink = &metrics.BlackholeSink{}
+	case 1:
+		ms.sink = fanout[0]
+	default:
+		ms.sink = fanout
+	}
+	return ms, nil
+}
+
+// prometheusSinkFromConfig also returns a function that removes the
+// sink's collectors from the registry, so that another sink can be
+// registered in their place
+func prometheusSinkFromConfig(cfg MetricsServerConfig, meters *meterRegistry) (metrics.MetricSink, func(), error) {
+	reg := cfg.Registry
+	if reg == nil {
+		reg = prom.DefaultRegisterer
+	}
+	opts := prometheus.DefaultPrometheusOpts
+	opts.Registerer = reg
+	next, err := prometheus.NewPrometheusSinkFrom(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	histograms := cfg.Histograms
+	if histograms == nil {
+		histograms = DefaultHistograms()
+	}
+	sink, err := newPrometheusSink(next, cfg.ServiceName, histograms, meters, reg)
+	if err != nil {
+		reg.Unregister(next)
+		return nil, nil, err
+	}
+	return sink, func() {
+		reg.Unregister(sink)
+		reg.Unregister(next)
+	}, nil
+}
+
+// inmemHandler serves the current contents of the in-memory sink as JSON
+func inmemHandler(sink *metrics.InmemSink) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		summary, err := sink.DisplayMetrics(w, r)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, summary)
+	})
+}
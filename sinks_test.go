@@ -0,0 +1,109 @@
+package go_spec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armon/go-metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSinksFromConfig(t *testing.T) {
+	cases := map[string]struct {
+		sinks              []SinkConfig
+		expectedPrometheus bool
+		expectedInmem      bool
+		expectedFanout     int
+		wantErr            bool
+	}{
+		"defaults to prometheus": {
+			expectedPrometheus: true,
+		},
+		"fans out to every sink": {
+			sinks:          []SinkConfig{{Type: "statsd"}, {Type: "DogStatsD", Tags: []string{"env:test"}}, {Type: "inmem"}},
+			expectedInmem:  true,
+			expectedFanout: 3,
+		},
+		"no sinks": {
+			sinks: []SinkConfig{},
+		},
+		"unknown sink": {
+			sinks:   []SinkConfig{{Type: "graphite"}},
+			wantErr: true,
+		},
+		"duplicate prometheus sink": {
+			sinks:   []SinkConfig{{Type: "prometheus"}, {Type: "prometheus"}},
+			wantErr: true,
+		},
+		"unknown sink after prometheus": {
+			sinks:   []SinkConfig{{Type: "prometheus"}, {Type: "graphite"}},
+			wantErr: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			reg := prom.NewRegistry()
+			sinks, err := sinksFromConfig(MetricsServerConfig{ServiceName: "test", Registry: reg, Sinks: c.sinks}, newMeterRegistry())
+			if c.wantErr {
+				assert.Error(t, err)
+			} else if assert.NoError(t, err) {
+				assert.Equal(t, c.expectedPrometheus, sinks.prometheus)
+				assert.Equal(t, c.expectedInmem, sinks.inmem != nil)
+				if c.expectedFanout > 0 {
+					assert.Len(t, sinks.sink, c.expectedFanout)
+				}
+				for _, shutdown := range sinks.shutdown {
+					shutdown()
+				}
+			}
+
+			// the Prometheus collectors have been released
+			_, err = sinksFromConfig(MetricsServerConfig{ServiceName: "test", Registry: reg}, newMeterRegistry())
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestMetricsServer_Endpoints(t *testing.T) {
+	cases := map[string]struct {
+		sinks         []SinkConfig
+		expectedCodes map[string]int
+	}{
+		"prometheus": {
+			sinks: DefaultSinks(),
+			expectedCodes: map[string]int{
+				DefaultObservabilityPath:            http.StatusOK,
+				DefaultObservabilityPath + "/inmem": http.StatusNotFound,
+			},
+		},
+		"inmem only": {
+			sinks: []SinkConfig{{Type: InmemSink}},
+			expectedCodes: map[string]int{
+				DefaultObservabilityPath:            http.StatusNotFound,
+				DefaultObservabilityPath + "/inmem": http.StatusOK,
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ms, err := NewDefaultMetricsServer(MetricsServerConfig{
+				ServiceName: "test",
+				Registry:    prom.NewRegistry(),
+				Sinks:       c.sinks,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			ms.metrics.IncrCounterWithLabels([]string{"requests"}, 1, []metrics.Label{{Name: "uri", Value: "/"}})
+			for path, code := range c.expectedCodes {
+				rec := httptest.NewRecorder()
+				ms.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(t, code, rec.Code, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/armory-io/go-spec/logging"
+)
+
+// Headers used by Spinnaker services to act on behalf of the calling
+// user, mirroring Kork's AuthenticatedRequest
+const (
+	SpinnakerUserHeader        = "X-SPINNAKER-USER"
+	SpinnakerAccountsHeader    = "X-SPINNAKER-ACCOUNTS"
+	SpinnakerUserOriginHeader  = "X-SPINNAKER-USER-ORIGIN"
+	SpinnakerExecutionIDHeader = "X-SPINNAKER-EXECUTION-ID"
+	SpinnakerApplicationHeader = "X-SPINNAKER-APPLICATION"
+)
+
+// spinnakerAuthHeaders are the headers carried from incoming to
+// outgoing requests
+var spinnakerAuthHeaders = []string{
+	SpinnakerUserHeader,
+	SpinnakerAccountsHeader,
+	SpinnakerUserOriginHeader,
+	SpinnakerExecutionIDHeader,
+	SpinnakerApplicationHeader,
+}
+
+type spinnakerAuthKey struct{}
+
+// ContextWithSpinnakerHeaders returns a copy of ctx carrying the Spinnaker
+// identity headers found in h. The user and execution ID are also attached to
+// log messages by LeveledLogger.WithContext
+func ContextWithSpinnakerHeaders(ctx context.Context, h http.Header) context.Context {
+	values := map[string]string{}
+	for _, name := range spinnakerAuthHeaders {
+		if v := h.Get(name); v != "" {
+			values[name] = v
+		}
+	}
+	if len(values) == 0 {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, spinnakerAuthKey{}, values)
+
+	fields := map[string]interface{}{}
+	if user := values[SpinnakerUserHeader]; user != "" {
+		fields[logging.UserField] = user
+	}
+	if executionID := values[SpinnakerExecutionIDHeader]; executionID != "" {
+		fields[logging.ExecutionIDField] = executionID
+	}
+	return logging.ContextWithFields(ctx, fields)
+}
+
+func spinnakerHeader(ctx context.Context, name string) string {
+	values, _ := ctx.Value(spinnakerAuthKey{}).(map[string]string)
+	return values[name]
+}
+
+// SpinnakerUser returns the user a request is being made on behalf of
+func SpinnakerUser(ctx context.Context) string {
+	return spinnakerHeader(ctx, SpinnakerUserHeader)
+}
+
+// SpinnakerAccounts returns the accounts the calling user is allowed to access
+func SpinnakerAccounts(ctx context.Context) []string {
+	accounts := spinnakerHeader(ctx, SpinnakerAccountsHeader)
+	if accounts == "" {
+		return nil
+	}
+	var result []string
+	for _, a := range strings.Split(accounts, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// SpinnakerUserOrigin returns the origin of the request, e.g. "deck" or "api"
+func SpinnakerUserOrigin(ctx context.Context) string {
+	return spinnakerHeader(ctx, SpinnakerUserOriginHeader)
+}
+
+// SpinnakerExecutionID returns the ID of the pipeline execution that made the request
+func SpinnakerExecutionID(ctx context.Context) string {
+	return spinnakerHeader(ctx, SpinnakerExecutionIDHeader)
+}
+
+// SpinnakerApplication returns the Spinnaker application the request was made for
+func SpinnakerApplication(ctx context.Context) string {
+	return spinnakerHeader(ctx, SpinnakerApplicationHeader)
+}
+
+// SpinnakerAuthMiddleware stores the Spinnaker identity headers of incoming
+// requests in the request's context so they can be read by handlers and
+// forwarded by SpinnakerAuthTransport
+func SpinnakerAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(ContextWithSpinnakerHeaders(r.Context(), r.Header)))
+	})
+}
+
+// SpinnakerAuthTransport re-attaches the Spinnaker identity headers stored in
+// each outgoing request's context so downstream services act on behalf of
+// the same user. Headers that are already set are kept
+func SpinnakerAuthTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		values, _ := req.Context().Value(spinnakerAuthKey{}).(map[string]string)
+		if len(values) == 0 {
+			return next.RoundTrip(req)
+		}
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(req.Context())
+		for name, v := range values {
+			if req.Header.Get(name) == "" {
+				req.Header.Set(name, v)
+			}
+		}
+		return next.RoundTrip(req)
+	})
+}
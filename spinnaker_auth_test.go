@@ -0,0 +1,61 @@
+package go_spec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/armory-io/go-spec/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpinnakerAuthMiddleware(t *testing.T) {
+	var ctx context.Context
+	h := SpinnakerAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(SpinnakerUserHeader, "jane@armory.io")
+	req.Header.Set(SpinnakerAccountsHeader, "prod, staging,,dev")
+	req.Header.Set(SpinnakerUserOriginHeader, "deck")
+	req.Header.Set(SpinnakerExecutionIDHeader, "01ABC")
+	req.Header.Set(SpinnakerApplicationHeader, "canals")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "jane@armory.io", SpinnakerUser(ctx))
+	assert.Equal(t, []string{"prod", "staging", "dev"}, SpinnakerAccounts(ctx))
+	assert.Equal(t, "deck", SpinnakerUserOrigin(ctx))
+	assert.Equal(t, "01ABC", SpinnakerExecutionID(ctx))
+	assert.Equal(t, "canals", SpinnakerApplication(ctx))
+	assert.Equal(t, map[string]interface{}{
+		logging.UserField:        "jane@armory.io",
+		logging.ExecutionIDField: "01ABC",
+	}, logging.FieldsFromContext(ctx))
+}
+
+func TestSpinnakerAuthTransport(t *testing.T) {
+	var received http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer downstream.Close()
+
+	incoming := http.Header{}
+	incoming.Set(SpinnakerUserHeader, "jane@armory.io")
+	incoming.Set(SpinnakerAccountsHeader, "prod")
+	ctx := ContextWithSpinnakerHeaders(context.Background(), incoming)
+
+	req, _ := http.NewRequest(http.MethodGet, downstream.URL, nil)
+	req.Header.Set(SpinnakerAccountsHeader, "override")
+	client := &http.Client{Transport: SpinnakerAuthTransport(nil)}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, "jane@armory.io", received.Get(SpinnakerUserHeader))
+	assert.Equal(t, "override", received.Get(SpinnakerAccountsHeader))
+	assert.Empty(t, received.Get(SpinnakerExecutionIDHeader))
+}
@@ -0,0 +1,288 @@
+package go_spec
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/armory-io/go-spec/logging"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation name of the spans created by go-spec
+const TracerName = "github.com/armory-io/go-spec"
+
+// Span exporters supported by TracingConfig
+const (
+	OTLPExporter   = "otlp"
+	StdoutExporter = "stdout"
+	FileExporter   = "file"
+)
+
+// TracingConfig configures distributed tracing. The ApplicationContext
+// reads it from the `tracing` block of the application's configuration
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Exporter is one of "otlp", "stdout" or "file"
+	Exporter string `yaml:"exporter"`
+	// Endpoint is the host:port of the OTLP/HTTP collector, the
+	// exporter's default, localhost:4318, is used when empty
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+	// Path is the file spans are appended to by the file exporter
+	Path string `yaml:"path"`
+	// SampleRatio is the fraction of new traces that are sampled. Requests
+	// that are part of a trace follow the sampling decision of their caller
+	SampleRatio float64 `yaml:"sampleRatio"`
+	// Propagators lists the formats used to read and write trace headers:
+	// "tracecontext", "baggage", "b3" (single header) and "b3multi".
+	// DefaultPropagators are used when empty
+	Propagators []string `yaml:"propagators"`
+}
+
+// DefaultPropagators read and write both W3C tracecontext and B3 headers
+var DefaultPropagators = []string{"tracecontext", "baggage", "b3"}
+
+// DefaultTracingConfig samples every trace and exports spans over OTLP.
+// Tracing is disabled
+func DefaultTracingConfig() TracingConfig {
+	return TracingConfig{
+		Exporter:    OTLPExporter,
+		SampleRatio: 1,
+	}
+}
+
+// TracingSettings is used to extract the tracing configuration
+// from the application's configuration
+type TracingSettings struct {
+	Tracing TracingConfig `yaml:"tracing"`
+}
+
+// Tracing creates server spans for incoming requests and client spans for
+// outgoing requests, propagating the trace between services through headers
+type Tracing struct {
+	cfg        TracingConfig
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+	closers    []func() error
+}
+
+// NewTracing creates the TracerProvider described by cfg. Spans are tagged
+// with serviceName. When tracing is disabled, the returned Tracing's
+// middleware and transport pass requests through untouched
+func NewTracing(ctx context.Context, serviceName string, cfg TracingConfig) (*Tracing, error) {
+	propagator, err := propagatorFromConfig(cfg.Propagators)
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.Enabled {
+		return &Tracing{cfg: cfg, propagator: propagator}, nil
+	}
+
+	var closers []func() error
+	var processor sdktrace.SpanProcessor
+	switch strings.ToLower(cfg.Exporter) {
+	case OTLPExporter, "":
+		opts := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	case StdoutExporter:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+	case FileExporter:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("tracing.path is required by the file exporter")
+		}
+		f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		processor = sdktrace.NewSimpleSpanProcessor(exporter)
+		closers = append(closers, f.Close)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %q", cfg.Exporter)
+	}
+
+	t := newTracing(serviceName, cfg, propagator, processor)
+	t.closers = closers
+	return t, nil
+}
+
+func newTracing(serviceName string, cfg TracingConfig, propagator propagation.TextMapPropagator, processor sdktrace.SpanProcessor) *Tracing {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceNameKey.String(serviceName),
+		)),
+	)
+	return &Tracing{
+		cfg:        cfg,
+		provider:   provider,
+		tracer:     provider.Tracer(TracerName),
+		propagator: propagator,
+	}
+}
+
+func propagatorFromConfig(names []string) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		names = DefaultPropagators
+	}
+	propagators := []propagation.TextMapPropagator{}
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "tracecontext":
+			propagators = append(propagators, propagation.TraceContext{})
+		case "baggage":
+			propagators = append(propagators, propagation.Baggage{})
+		case "b3":
+			propagators = append(propagators, b3.New())
+		case "b3multi":
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		default:
+			return nil, fmt.Errorf("unknown trace propagator: %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
+
+// Enabled reports whether spans are being recorded
+func (t *Tracing) Enabled() bool {
+	return t.provider != nil
+}
+
+// TracerProvider returns the provider spans are created with. It is nil
+// when tracing is disabled
+func (t *Tracing) TracerProvider() *sdktrace.TracerProvider {
+	return t.provider
+}
+
+// Propagator returns the propagator used to read and write trace headers
+func (t *Tracing) Propagator() propagation.TextMapPropagator {
+	return t.propagator
+}
+
+// SetGlobal makes the provider and propagator the ones returned by the
+// otel package, so that spans created through otel.Tracer are exported too
+func (t *Tracing) SetGlobal() {
+	if t.Enabled() {
+		otel.SetTracerProvider(t.provider)
+	}
+	otel.SetTextMapPropagator(t.propagator)
+}
+
+// Middleware creates a server span for every request, continuing the trace
+// found in the request's headers. Spans are named by the path template of
+// the gorilla/mux route that handled the request when the router is served
+// by the ApplicationContext, e.g. "/applications/{name}". The trace and span
+// IDs are added to the fields logged through the request's context
+func (t *Tracing) Middleware(next http.Handler) http.Handler {
+	if !t.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := t.propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := t.tracer.Start(ctx, "HTTP "+r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", r)...),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("", "", r)...),
+		)
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = logging.ContextWithFields(ctx, map[string]interface{}{
+				logging.TraceIDField: sc.TraceID().String(),
+				logging.SpanIDField:  sc.SpanID().String(),
+			})
+		}
+
+		r, info := withRequestInfo(r.WithContext(ctx))
+		wrappedWriter := wrapResponseWriter(w)
+		next.ServeHTTP(wrappedWriter, r)
+
+		if info.uriTemplate != "" {
+			span.SetName(info.uriTemplate)
+			span.SetAttributes(semconv.HTTPRouteKey.String(info.uriTemplate))
+		}
+		code := wrappedWriter.status()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(code)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(code, trace.SpanKindServer))
+	})
+}
+
+// Transport wraps next so that every outgoing request creates a client span
+// and carries the trace headers. Spans are named by the uri template set with
+// ContextWithURITemplate, or by the request's method when there is none
+func (t *Tracing) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if !t.Enabled() {
+		return next
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		name := "HTTP " + req.Method
+		if tmpl, ok := req.Context().Value(uriTemplateKey{}).(string); ok && tmpl != "" {
+			name = tmpl
+		}
+		ctx, span := t.tracer.Start(req.Context(), name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...),
+		)
+		defer span.End()
+
+		req = req.Clone(ctx)
+		t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return resp, err
+		}
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, trace.SpanKindClient))
+		return resp, nil
+	})
+}
+
+// Shutdown flushes pending spans and stops the provider
+func (t *Tracing) Shutdown(ctx context.Context) error {
+	if !t.Enabled() {
+		return nil
+	}
+	err := t.provider.Shutdown(ctx)
+	for _, c := range t.closers {
+		if cerr := c(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
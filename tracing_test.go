@@ -0,0 +1,156 @@
+package go_spec
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/armory-io/go-spec/logging"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestTracing(t *testing.T) (*Tracing, *tracetest.SpanRecorder) {
+	cfg := DefaultTracingConfig()
+	cfg.Enabled = true
+	propagator, err := propagatorFromConfig(cfg.Propagators)
+	assert.NoError(t, err)
+	recorder := tracetest.NewSpanRecorder()
+	return newTracing("test", cfg, propagator, recorder), recorder
+}
+
+func TestPropagatorFromConfig(t *testing.T) {
+	cases := map[string]struct {
+		names   []string
+		fields  []string
+		wantErr bool
+	}{
+		"tracecontext and b3": {
+			names:  []string{"tracecontext", "b3"},
+			fields: []string{"traceparent", "tracestate", "x-b3-traceid"},
+		},
+		"defaults": {
+			fields: []string{"traceparent", "baggage", "x-b3-traceid"},
+		},
+		"b3 multiple headers": {
+			names:  []string{"B3Multi"},
+			fields: []string{"x-b3-traceid", "x-b3-spanid", "x-b3-sampled", "x-b3-flags"},
+		},
+		"unknown propagator": {
+			names:   []string{"jaeger"},
+			wantErr: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, err := propagatorFromConfig(c.names)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			for _, f := range c.fields {
+				assert.Contains(t, p.Fields(), f)
+			}
+		})
+	}
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	tracing, recorder := newTestTracing(t)
+	var fields map[string]interface{}
+	router := mux.NewRouter()
+	router.Use(routeTemplateMiddleware)
+	router.HandleFunc("/applications/{name}", func(w http.ResponseWriter, r *http.Request) {
+		fields = logging.FieldsFromContext(r.Context())
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/applications/deck", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	tracing.Middleware(router).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 1) {
+		span := spans[0]
+		assert.Equal(t, "/applications/{name}", span.Name())
+		assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+		assert.Equal(t, "Error", span.Status().Code.String())
+		assert.Equal(t, span.SpanContext().TraceID().String(), fields[logging.TraceIDField])
+		assert.Equal(t, span.SpanContext().SpanID().String(), fields[logging.SpanIDField])
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	tracing, recorder := newTestTracing(t)
+	var headers http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+	}))
+	defer srv.Close()
+
+	ctx, parent := tracing.tracer.Start(context.Background(), "parent")
+	ctx = ContextWithURITemplate(ctx, "/applications/{application}")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/applications/deck", nil)
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	resp, err := client.Do(req)
+	parent.End()
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	spans := recorder.Ended()
+	if assert.Len(t, spans, 2) {
+		span := spans[0]
+		assert.Equal(t, "/applications/{application}", span.Name())
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		assert.Contains(t, headers.Get("traceparent"), span.SpanContext().SpanID().String())
+		assert.Contains(t, headers.Get("b3"), span.SpanContext().SpanID().String())
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	tracing, err := NewTracing(context.Background(), "test", DefaultTracingConfig())
+	assert.NoError(t, err)
+	assert.False(t, tracing.Enabled())
+
+	var traced bool
+	h := tracing.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = trace.SpanContextFromContext(r.Context()).IsValid()
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.False(t, traced)
+	assert.NoError(t, tracing.Shutdown(context.Background()))
+}
+
+func TestTracingFileExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "spans.json")
+	cfg := DefaultTracingConfig()
+	cfg.Enabled = true
+	cfg.Exporter = FileExporter
+	cfg.Path = path
+
+	tracing, err := NewTracing(context.Background(), "test", cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, span := tracing.TracerProvider().Tracer("test").Start(context.Background(), "exported")
+	span.End()
+	assert.NoError(t, tracing.Shutdown(context.Background()))
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"Name":"exported"`)
+}
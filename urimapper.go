@@ -0,0 +1,120 @@
+package go_spec
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ServeMux is an http.ServeMux that records the pattern each request was
+// routed by, so that metrics, access logs and traces report it as the uri
+// template rather than the request's path
+type ServeMux struct {
+	*http.ServeMux
+}
+
+// NewServeMux creates an empty ServeMux
+func NewServeMux() *ServeMux {
+	return &ServeMux{ServeMux: http.NewServeMux()}
+}
+
+func (m *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if info := requestInfoFrom(r); info != nil {
+		if _, pattern := m.Handler(r); pattern != "" {
+			info.uriTemplate = patternPath(pattern)
+		}
+	}
+	m.ServeMux.ServeHTTP(w, r)
+}
+
+// patternPath strips the host from a ServeMux pattern, e.g.
+// "example.com/applications/". The module supports Go releases before
+// 1.22, so ServeMux patterns are the legacy ones, without methods or wildcards
+func patternPath(pattern string) string {
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// URIPatternConfig maps the requests matching a pattern to a uri template.
+// Pattern uses chi's syntax: "{name}" matches a single path segment,
+// "{name:[0-9]+}" a segment matching the regular expression and a trailing
+// "*" the rest of the path. Pattern is used as the template. Alternatively,
+// Regex is matched against the request's path and reported as Template
+type URIPatternConfig struct {
+	Pattern  string `yaml:"pattern"`
+	Regex    string `yaml:"regex"`
+	Template string `yaml:"template"`
+}
+
+type uriPattern struct {
+	re       *regexp.Regexp
+	template string
+}
+
+// PatternURIMapperFunc returns a URIMapperFunc that reports the template of
+// the first pattern matching the request's path. Requests that don't match
+// any pattern are mapped by fallback, DefaultURIMapperFunc when nil
+func PatternURIMapperFunc(patterns []URIPatternConfig, fallback URIMapperFunc) (URIMapperFunc, error) {
+	if fallback == nil {
+		fallback = DefaultURIMapperFunc
+	}
+	compiled := make([]uriPattern, 0, len(patterns))
+	for _, p := range patterns {
+		var up uriPattern
+		var err error
+		switch {
+		case p.Pattern != "":
+			up.template = p.Pattern
+			up.re, err = compileChiPattern(p.Pattern)
+		case p.Regex != "" && p.Template != "":
+			up.template = p.Template
+			up.re, err = regexp.Compile(p.Regex)
+		default:
+			err = fmt.Errorf("either pattern, or both regex and template, are required")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid uri pattern %q: %w", p.Pattern+p.Regex, err)
+		}
+		compiled = append(compiled, up)
+	}
+	return func(r *http.Request) string {
+		path := r.URL.Path
+		for _, p := range compiled {
+			if p.re.MatchString(path) {
+				return p.template
+			}
+		}
+		return fallback(r)
+	}, nil
+}
+
+// compileChiPattern converts a chi style pattern into a regular expression
+func compileChiPattern(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '{':
+			end := strings.IndexByte(pattern[i:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed parameter")
+			}
+			param := pattern[i+1 : i+end]
+			expr := "[^/]+"
+			if j := strings.IndexByte(param, ':'); j >= 0 {
+				expr = param[j+1:]
+			}
+			b.WriteString("(?:" + expr + ")")
+			i += end
+		case c == '*' && i == len(pattern)-1:
+			b.WriteString(".*")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,157 @@
+package go_spec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternURIMapperFunc(t *testing.T) {
+	mapper, err := PatternURIMapperFunc([]URIPatternConfig{
+		{Pattern: "/applications/{name}"},
+		{Pattern: "/applications/{name}/pipelines/{id:[0-9]+}"},
+		{Pattern: "/static/*"},
+		{Regex: "^/v[0-9]+/tasks/[^/]+$", Template: "/v{version}/tasks/{id}"},
+	}, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cases := map[string]struct {
+		path     string
+		expected string
+	}{
+		"single segment": {
+			path:     "/applications/deck",
+			expected: "/applications/{name}",
+		},
+		"constrained segment": {
+			path:     "/applications/deck/pipelines/42",
+			expected: "/applications/{name}/pipelines/{id:[0-9]+}",
+		},
+		"constraint not met": {
+			path:     "/applications/deck/pipelines/deploy",
+			expected: "/applications/deck/pipelines/deploy",
+		},
+		"wildcard": {
+			path:     "/static/js/app.js",
+			expected: "/static/*",
+		},
+		"regex": {
+			path:     "/v2/tasks/01F8MECHZX3TBDSZ7XRADM79XE",
+			expected: "/v{version}/tasks/{id}",
+		},
+		"unmatched paths fall back": {
+			path:     "/health?verbose=true",
+			expected: "/health",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, c.expected, mapper(httptest.NewRequest(http.MethodGet, c.path, nil)))
+		})
+	}
+}
+
+func TestPatternURIMapperFunc_Invalid(t *testing.T) {
+	cases := map[string]URIPatternConfig{
+		"unclosed parameter":     {Pattern: "/applications/{name"},
+		"invalid regex":          {Regex: "(", Template: "/"},
+		"regex without template": {Regex: "^/applications$"},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := PatternURIMapperFunc([]URIPatternConfig{c}, nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestServeMux(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/applications/", func(w http.ResponseWriter, r *http.Request) {})
+
+	ms, sink := newTestMetricsServer(t)
+	ms.RequestMetricsMiddleware(mux).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/applications/deck", nil))
+
+	var uris []string
+	for _, interval := range sink.Data() {
+		for _, sample := range interval.Samples {
+			uris = append(uris, labelValue(sample.Labels, "uri"))
+		}
+	}
+	assert.Equal(t, []string{"/applications/"}, uris)
+}
+
+func TestMetricsServer_InstrumentWith(t *testing.T) {
+	mapper, _ := PatternURIMapperFunc([]URIPatternConfig{{Pattern: "/applications/{name}"}}, nil)
+	configured := func(r *http.Request) string { return "/configured" }
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	cases := map[string]struct {
+		configured URIMapperFunc
+		instrument func(ms *MetricsServer) http.Handler
+		expected   string
+	}{
+		"explicit mapper": {
+			instrument: func(ms *MetricsServer) http.Handler { return ms.InstrumentWith(mapper)(handler) },
+			expected:   "/applications/{name}",
+		},
+		"explicit mapper wins over configured one": {
+			configured: configured,
+			instrument: func(ms *MetricsServer) http.Handler { return ms.InstrumentWith(mapper)(handler) },
+			expected:   "/applications/{name}",
+		},
+		"configured mapper is the default": {
+			configured: configured,
+			instrument: func(ms *MetricsServer) http.Handler { return ms.RequestMetricsMiddleware(handler) },
+			expected:   "/configured",
+		},
+		"mux router falls back to configured mapper": {
+			configured: configured,
+			instrument: func(ms *MetricsServer) http.Handler {
+				router := mux.NewRouter()
+				router.NotFoundHandler = handler
+				return ms.InstrumentMuxRouter(router)
+			},
+			expected: "/configured",
+		},
+		"mux router with explicit mapper": {
+			configured: configured,
+			instrument: func(ms *MetricsServer) http.Handler { return ms.InstrumentMuxRouterWith(mapper)(handler) },
+			expected:   "/applications/{name}",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ms, sink := newTestMetricsServer(t)
+			ms.uriMapper = c.configured
+			c.instrument(ms).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/applications/deck", nil))
+
+			var uris []string
+			for _, interval := range sink.Data() {
+				for _, sample := range interval.Samples {
+					uris = append(uris, labelValue(sample.Labels, "uri"))
+				}
+			}
+			assert.Equal(t, []string{c.expected}, uris)
+		})
+	}
+}
+
+func TestPatternPath(t *testing.T) {
+	cases := map[string]string{
+		"/applications/":             "/applications/",
+		"/applications":              "/applications",
+		"example.com/applications/":  "/applications/",
+		"example.com/applications/a": "/applications/a",
+	}
+	for pattern, expected := range cases {
+		assert.Equal(t, expected, patternPath(pattern))
+	}
+}